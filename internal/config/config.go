@@ -236,14 +236,170 @@ type Database struct {
 // }
 
 type AppConfigMessageGateway struct {
+	Name     string `json:"name"` // unique in channel gateways list
 	From     string `json:"from"`
 	URL      string `json:"url"`
 	Query    string `json:"query"`
 	Body     string `json:"body"`
+	BodyType string `json:"body_type"` // form (default), json, json_nested
 	User     string `json:"credentials"`
 	Password string `json:"password"`
 	Stdout   bool   `json:"stdout"`
 	HTTP     bool   `json:"http"`
+	Sandbox  bool   `json:"sandbox"` // capture in memory instead of send, for tests
+
+	Request AppConfigGatewayRequest `json:"request"` // used instead of url, query, body if request url is set
+
+	SMTP     bool   `json:"smtp"` // email only, used instead of http
+	SMTPHost string `json:"smtp_host"`
+	SMTPPort int    `json:"smtp_port"` // default by smtp_tls: 25, 587, 465
+	SMTPTLS  string `json:"smtp_tls"`  // starttls, tls, none
+	SMTPAuth string `json:"smtp_auth"` // plain, login, none
+
+	ResponseID string                  `json:"response_id"` // path of provider message id in json response, messages.0.id
+	Receipt    AppConfigGatewayReceipt `json:"receipt"`
+
+	Cost AppConfigGatewayCost `json:"cost"`
+}
+
+// AppConfigGatewayCost price per message and spend caps of gateway, cap is off if 0
+type AppConfigGatewayCost struct {
+	Price      float64            `json:"price"`       // per message
+	Prices     map[string]float64 `json:"prices"`      // per message by phone prefix, +44, longest prefix wins
+	DailyCap   float64            `json:"daily_cap"`   // UTC day
+	MonthlyCap float64            `json:"monthly_cap"` // UTC month
+}
+
+// AppConfigGatewayReceipt delivery receipt webhook, field names are paths in json or form keys
+type AppConfigGatewayReceipt struct {
+	Token       string            `json:"token"`        // webhook enabled if not empty, token query param or X-Webhook-Token header
+	Items       string            `json:"items"`        // path of receipts list, body is single receipt or list if empty
+	IDField     string            `json:"id_field"`     // provider message id
+	StatusField string            `json:"status_field"` // provider status
+	ErrorField  string            `json:"error_field"`
+	Statuses    map[string]string `json:"statuses"` // provider status to delivered, undelivered, bounced, sent
+}
+
+type AppConfigRetry struct {
+	MaxAttempts int     `json:"max_attempts"` // total attempts, no retry if <= 1
+	BaseDelay   int     `json:"base_delay_msec"`
+	MaxDelay    int     `json:"max_delay_msec"`
+	Jitter      float64 `json:"jitter"` // 0..1
+}
+
+type AppConfigMessenger struct {
+	Retry AppConfigRetry `json:"retry"`
+
+	SmsPasscodeMaxAge   int `json:"sms_passcode_max_age"`   // seconds, 0 no expiry
+	EmailPasscodeMaxAge int `json:"email_passcode_max_age"` // seconds, 0 no expiry
+
+	IdempotencyWindow int    `json:"idempotency_window"` // seconds, repeated Idempotency-Key returns stored response
+	IdempotencyLease  int    `json:"idempotency_lease"`  // seconds, key in progress is reserved, longer than request
+	IdempotencyStore  string `json:"idempotency_store"`  // memory (single replica), db (shared by replicas)
+
+	BatchMaxSize int `json:"batch_max_size"` // messages per batch request
+
+	SandboxSize int `json:"sandbox_size"` // messages captured by sandbox gateways, oldest dropped
+
+	Priority AppConfigPriority `json:"priority"` // send queue lanes, passcodes are high priority
+
+	ScheduleInterval int `json:"schedule_interval"`  // seconds, check of scheduled messages due
+	ScheduleMaxDelay int `json:"schedule_max_delay"` // seconds, latest send time accepted
+
+	// seconds, pending messages of replica are renewed every schedule interval and claimed by other replica
+	// after lease is expired
+	OutboxLease int `json:"outbox_lease"`
+
+	OTP AppConfigOTP `json:"otp"`
+
+	Throttle AppConfigThrottle `json:"throttle"`
+
+	Recipients AppConfigRecipients `json:"recipients"`
+
+	// template names to load from config path as email_<name>.<lang>.html and sms_<name>.<lang>.txt,
+	// embedded ones are loaded always
+	Templates    []string `json:"templates"`
+	SmsTemplates []string `json:"sms_templates"`
+
+	ChatRooms map[string]AppConfigChatRoom `json:"chat_rooms"` // by room name
+}
+
+// AppConfigChatRoom incoming webhook of chat room, slack or mattermost
+type AppConfigChatRoom struct {
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	IconURL  string `json:"icon_url"`
+}
+
+// AppConfigRecipients recipient normalization and validation
+type AppConfigRecipients struct {
+	PhoneDefaultRegion string   `json:"phone_default_region"` // ISO 3166-1 alpha-2, national format numbers accepted if set
+	PhoneAllowPrefix   []string `json:"phone_allow_prefix"`   // E.164 prefixes, +44, +1876, all allowed if empty
+	PhoneDenyPrefix    []string `json:"phone_deny_prefix"`
+	EmailDenyDomains   []string `json:"email_deny_domains"` // disposable domains, subdomains included
+}
+
+// AppConfigThrottle passcode endpoints abuse limits
+type AppConfigThrottle struct {
+	Store string                  `json:"store"` // memory (single replica), db (shared by replicas)
+	Rules []AppConfigThrottleRule `json:"rules"`
+}
+
+// AppConfigThrottleRule sliding window limit, limit requests per window seconds for every key value
+type AppConfigThrottleRule struct {
+	Name    string   `json:"name"`    // metric label, default key_limit_window
+	Key     string   `json:"key"`     // recipient, ip, country
+	Channel string   `json:"channel"` // sms, email, empty for all
+	Prefix  []string `json:"prefix"`  // phone prefixes rule applies to, empty for all
+	Limit   int      `json:"limit"`
+	Window  int      `json:"window"` // seconds
+}
+
+// AppConfigPriority scheduling between high, normal and low lanes of send queue of channel
+type AppConfigPriority struct {
+	Scheduling string                           `json:"scheduling"` // strict (default), weighted
+	Lanes      map[string]AppConfigPriorityLane `json:"lanes"`      // by high, normal, low
+}
+
+type AppConfigPriorityLane struct {
+	Weight  int `json:"weight"`   // share of weighted scheduling, default 1
+	MaxSize int `json:"max_size"` // queued messages, no limit if 0
+}
+
+// AppConfigOTP server side generated one time passcodes
+type AppConfigOTP struct {
+	Length      int    `json:"length"`
+	Alphabet    string `json:"alphabet"`
+	TTL         int    `json:"ttl"`          // seconds
+	MaxAttempts int    `json:"max_attempts"` // verify attempts, code is invalidated after
+	Pepper      string `json:"pepper"`       // secret key of code hashes, not stored in database
+}
+
+// AppConfigChannel message channel, sms and email are declared by default
+type AppConfigChannel struct {
+	Name     string                    `json:"name"`
+	Type     string                    `json:"type"`     // sms, email, default name
+	Workers  int                       `json:"workers"`  // senders of task queue, default 1
+	Gateways []AppConfigMessageGateway `json:"gateways"` // default gateways of type, sms_gateway(s) or email_gateway(s)
+	Routes   []AppConfigGatewayRoute   `json:"routes"`
+}
+
+// AppConfigGatewayRoute route message to gateway if all not empty conditions match
+type AppConfigGatewayRoute struct {
+	Gateway     string   `json:"gateway"`      // gateway name
+	PhonePrefix []string `json:"phone_prefix"` // +44, +1
+	EmailDomain []string `json:"email_domain"` // example.com, subdomains included
+	Lang        []string `json:"lang"`
+}
+
+// AppConfigGatewayRequest gateway request, url, headers and body are text/template
+// over {{.Msg.to}}, {{.Const.name}}, {{.From}}, {{.User}}, {{.Password}}
+type AppConfigGatewayRequest struct {
+	Method  string            `json:"method"` // default POST
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+	Consts  map[string]string `json:"consts"`
 }
 
 type AppConfigVault struct {
@@ -254,6 +410,13 @@ type AppConfigLang struct {
 	Langs []string `json:"langs"`
 }
 
+// AppConfigRedaction masking of sensitive data in log output
+type AppConfigRedaction struct {
+	Phone    bool     `json:"phone"`    // +44*******00
+	Email    bool     `json:"email"`    // j***@example.com
+	Patterns []string `json:"patterns"` // regexp, group 1 or whole match is masked
+}
+
 type AppConfigMod struct {
 	Name  string `json:"-"`
 	Env   string `json:"env"` // prod||'' dev stage
@@ -277,6 +440,18 @@ type AppConfig struct {
 	SmsGateway   AppConfigMessageGateway `json:"sms_gateway"`
 	EmailGateway AppConfigMessageGateway `json:"email_gateway"`
 
+	// named gateways, used instead of single gateway if not empty
+	SmsGateways   []AppConfigMessageGateway `json:"sms_gateways"`
+	EmailGateways []AppConfigMessageGateway `json:"email_gateways"`
+	SmsRoutes     []AppConfigGatewayRoute   `json:"sms_routes"`
+	EmailRoutes   []AppConfigGatewayRoute   `json:"email_routes"`
+
+	Channels []AppConfigChannel `json:"channels"`
+
+	Messenger AppConfigMessenger `json:"messenger"`
+
+	Redaction AppConfigRedaction `json:"redaction"`
+
 	HTTPTransport AppConfigHTTPTransport `json:"http_transport"`
 
 	HTTPServer AppConfigHTTPServer `json:"http_server"`
@@ -327,6 +502,7 @@ func NewAppConfig() *AppConfig {
 			URL:      "",
 			Query:    "",
 			Body:     "",
+			BodyType: "form",
 			User:     "",
 			Password: "",
 			Stdout:   true,
@@ -338,12 +514,67 @@ func NewAppConfig() *AppConfig {
 			URL:      "",
 			Query:    "",
 			Body:     "",
+			BodyType: "form",
 			User:     "",
 			Password: "",
 			Stdout:   true,
 			HTTP:     true,
 		},
 
+		Messenger: AppConfigMessenger{
+			Retry: AppConfigRetry{
+				MaxAttempts: 3,
+				BaseDelay:   1000,
+				MaxDelay:    30000,
+				Jitter:      0.2,
+			},
+			SmsPasscodeMaxAge:   30,
+			EmailPasscodeMaxAge: 0,
+			IdempotencyWindow:   86400,
+			IdempotencyLease:    60,
+			IdempotencyStore:    "memory",
+			BatchMaxSize:        1000,
+			SandboxSize:         1000,
+			ScheduleInterval:    5,
+			ScheduleMaxDelay:    30 * 86400,
+			OutboxLease:         60,
+			Priority: AppConfigPriority{
+				Scheduling: "strict",
+				Lanes: map[string]AppConfigPriorityLane{
+					"high":   {Weight: 8},
+					"normal": {Weight: 2},
+					"low":    {Weight: 1},
+				},
+			},
+			OTP: AppConfigOTP{
+				Length:      6,
+				Alphabet:    "0123456789",
+				TTL:         300,
+				MaxAttempts: 5,
+			},
+			Recipients: AppConfigRecipients{
+				EmailDenyDomains: []string{
+					"mailinator.com", "guerrillamail.com", "guerrillamail.net", "sharklasers.com", "10minutemail.com",
+					"temp-mail.org", "tempmail.com", "yopmail.com", "trashmail.com", "getnada.com", "dispostable.com",
+					"maildrop.cc", "throwawaymail.com", "fakeinbox.com", "mailnesia.com", "mohmal.com",
+				},
+			},
+			Throttle: AppConfigThrottle{
+				Store: "memory",
+				Rules: []AppConfigThrottleRule{
+					{Key: "recipient", Limit: 3, Window: 600},
+					{Key: "recipient", Limit: 10, Window: 86400},
+					{Key: "ip", Limit: 30, Window: 3600},
+				},
+			},
+		},
+
+		Redaction: AppConfigRedaction{
+			Phone:    true,
+			Email:    true,
+			Patterns: []string{`(?i)(?:passcode|code|otp|pin)\W{1,3}(\w{4,12})`},
+		},
+
 		HTTPTransport: AppConfigHTTPTransport{},
 
 		HTTPServer: AppConfigHTTPServer{
@@ -415,20 +646,55 @@ func (x *AppConfig) readEnvVar() error {
 	reader.String(&x.SmsGateway.URL, "sms_gw_url", nil)
 	reader.String(&x.SmsGateway.Query, "sms_gw_query", nil)
 	reader.String(&x.SmsGateway.Body, "sms_gw_body", nil)
+	reader.String(&x.SmsGateway.BodyType, "sms_gw_body_type", nil)
 	reader.String(&x.SmsGateway.User, "sms_gw_user", nil)
 	reader.String(&x.SmsGateway.Password, "sms_gw_password", nil)
 	reader.Bool(&x.SmsGateway.Stdout, "sms_gw_stdout", nil)
 	reader.Bool(&x.SmsGateway.HTTP, "sms_gw_http", nil)
+	reader.Bool(&x.SmsGateway.Sandbox, "sms_gw_sandbox", nil)
 
 	// EmailGateway configuration
 	reader.String(&x.EmailGateway.From, "email_gw_from", nil)
 	reader.String(&x.EmailGateway.URL, "email_gw_url", nil)
 	reader.String(&x.EmailGateway.Query, "email_gw_query", nil)
 	reader.String(&x.EmailGateway.Body, "email_gw_body", nil)
+	reader.String(&x.EmailGateway.BodyType, "email_gw_body_type", nil)
 	reader.String(&x.EmailGateway.User, "email_gw_user", nil)
 	reader.String(&x.EmailGateway.Password, "email_gw_password", nil)
 	reader.Bool(&x.EmailGateway.Stdout, "email_gw_stdout", nil)
 	reader.Bool(&x.EmailGateway.HTTP, "email_gw_http", nil)
+	reader.Bool(&x.EmailGateway.Sandbox, "email_gw_sandbox", nil)
+	reader.Bool(&x.EmailGateway.SMTP, "email_gw_smtp", nil)
+	reader.String(&x.EmailGateway.SMTPHost, "email_gw_smtp_host", nil)
+	reader.Int(&x.EmailGateway.SMTPPort, "email_gw_smtp_port", nil)
+	reader.String(&x.EmailGateway.SMTPTLS, "email_gw_smtp_tls", nil)
+	reader.String(&x.EmailGateway.SMTPAuth, "email_gw_smtp_auth", nil)
+
+	// Messenger configuration
+	reader.Int(&x.Messenger.Retry.MaxAttempts, "messenger_retry_max_attempts", nil)
+	reader.Int(&x.Messenger.Retry.BaseDelay, "messenger_retry_base_delay_msec", nil)
+	reader.Int(&x.Messenger.Retry.MaxDelay, "messenger_retry_max_delay_msec", nil)
+	reader.Float64(&x.Messenger.Retry.Jitter, "messenger_retry_jitter", nil)
+	reader.Int(&x.Messenger.SmsPasscodeMaxAge, "messenger_sms_passcode_max_age", nil)
+	reader.Int(&x.Messenger.EmailPasscodeMaxAge, "messenger_email_passcode_max_age", nil)
+	reader.Int(&x.Messenger.IdempotencyWindow, "messenger_idempotency_window", nil)
+	reader.Int(&x.Messenger.IdempotencyLease, "messenger_idempotency_lease", nil)
+	reader.String(&x.Messenger.IdempotencyStore, "messenger_idempotency_store", nil)
+	reader.Int(&x.Messenger.BatchMaxSize, "messenger_batch_max_size", nil)
+	reader.Int(&x.Messenger.SandboxSize, "messenger_sandbox_size", nil)
+	reader.String(&x.Messenger.Priority.Scheduling, "messenger_priority_scheduling", nil)
+	reader.Bool(&x.Redaction.Phone, "redaction_phone", nil)
+	reader.Bool(&x.Redaction.Email, "redaction_email", nil)
+	reader.Int(&x.Messenger.ScheduleInterval, "messenger_schedule_interval", nil)
+	reader.Int(&x.Messenger.OutboxLease, "messenger_outbox_lease", nil)
+	reader.Int(&x.Messenger.ScheduleMaxDelay, "messenger_schedule_max_delay", nil)
+	reader.Int(&x.Messenger.OTP.Length, "messenger_otp_length", nil)
+	reader.String(&x.Messenger.OTP.Alphabet, "messenger_otp_alphabet", nil)
+	reader.Int(&x.Messenger.OTP.TTL, "messenger_otp_ttl", nil)
+	reader.Int(&x.Messenger.OTP.MaxAttempts, "messenger_otp_max_attempts", nil)
+	reader.String(&x.Messenger.OTP.Pepper, "messenger_otp_pepper", nil)
+	reader.String(&x.Messenger.Throttle.Store, "messenger_throttle_store", nil)
+	reader.String(&x.Messenger.Recipients.PhoneDefaultRegion, "messenger_phone_default_region", nil)
 
 	// Database configuration
 
@@ -463,6 +729,7 @@ func (x *AppConfig) readEnvVar() error {
 	reader.Int(&x.HTTPServer.ReadHeaderTimeout, "http_read_header_timeout", nil)
 	reader.String(&x.HTTPServer.ListenSys, "http_listen_sys", nil)  // =>listen_sys
 	reader.String(&x.HTTPServer.SysAPIKey, "http_sys_api_key", nil) // =>sys_api_key
+	reader.String(&x.HTTPServer.TrustedProxies, "http_trusted_proxies", nil)
 
 	reader.String(&x.HTTPServer.CertDir, "cert_dir", &CmdLine.CertDir) // short
 	reader.String(&x.Configs.Dir, "configs_dir", &CmdLine.ConfigsDir)
@@ -548,6 +815,10 @@ type AppConfigHTTPServer struct {
 	SysMetrics bool   `json:"sys_metrics"` //
 	SysAPIKey  string `json:"sys_api_key"`
 	ListenSys  string `json:"listen_sys"`
+
+	// comma separated CIDRs of reverse proxies, client IP is taken from X-Forwarded-For set by them,
+	// peer address if empty, headers of clients are not trusted
+	TrustedProxies string `json:"trusted_proxies"`
 }
 type AppConfigConfigs struct {
 	Dir string `json:"dir"`
@@ -0,0 +1,328 @@
+package controller
+
+import (
+	"fmt"
+	"go-infra/internal/service"
+	"maps"
+	"net/http"
+	"time"
+)
+
+// batchDTO list of messages or one template with many recipients
+type batchDTO struct {
+	Messages []messageDTO `json:"messages"`
+
+	// template mode, recipient lang and data override shared ones
+	Template   string              `json:"template"`
+	Lang       string              `json:"lang"`
+	Subject    string              `json:"subject"`
+	Data       map[string]any      `json:"data"`
+	SendAt     string              `json:"send_at"`
+	Timezone   string              `json:"timezone"`
+	Delay      int                 `json:"delay"`
+	Recipients []batchRecipientDTO `json:"recipients"`
+}
+
+// batchRecipientDTO recipient of template, timezone for send_at in recipient local time
+type batchRecipientDTO struct {
+	To       string         `json:"to"`
+	Lang     string         `json:"lang"`
+	Timezone string         `json:"timezone"`
+	Data     map[string]any `json:"data"`
+}
+
+// batchItemDTO result of batch message by index of request
+type batchItemDTO struct {
+	Index   int        `json:"index"`
+	ID      string     `json:"id,omitempty"`
+	Status  string     `json:"status"` // queued, scheduled or rejection code
+	SendAt  *time.Time `json:"send_at,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+// batchAcceptedDTO response on batch
+type batchAcceptedDTO struct {
+	Accepted int            `json:"accepted"`
+	Rejected int            `json:"rejected"`
+	Items    []batchItemDTO `json:"items"`
+}
+
+// messages batch as list of messages
+func (x batchDTO) messages() []messageDTO {
+
+	if x.Template == "" {
+		return x.Messages
+	}
+
+	res := make([]messageDTO, 0, len(x.Recipients))
+
+	for _, itm := range x.Recipients {
+
+		data := maps.Clone(x.Data)
+		if data == nil {
+			data = map[string]any{}
+		}
+		maps.Copy(data, itm.Data)
+
+		lang := itm.Lang
+		if lang == "" {
+			lang = x.Lang
+		}
+
+		timezone := itm.Timezone
+		if timezone == "" {
+			timezone = x.Timezone
+		}
+
+		res = append(res, messageDTO{
+			To:       itm.To,
+			Lang:     lang,
+			Template: x.Template,
+			Data:     data,
+			Subject:  x.Subject,
+			SendAt:   x.SendAt,
+			Timezone: timezone,
+			Delay:    x.Delay,
+		})
+	}
+
+	return res
+}
+
+// SmsBatch send many sms, each message is validated independently
+func (x *MessengerController) SmsBatch() error {
+
+	sender, rejected := channelSender(x, x.appService.SmsSender())
+	if rejected != nil {
+		return x.reject(rejected)
+	}
+
+	list, rejected := x.batchMessages()
+	if rejected != nil {
+		return x.reject(rejected)
+	}
+
+	res := batchAcceptedDTO{Items: make([]batchItemDTO, len(list))}
+	messages := []service.SmsMessage{}
+	sendAts := []time.Time{}
+	indexes := []int{}
+
+	for i := range list {
+
+		message, rejected, err := x.smsBatchMessage(&list[i])
+		if err != nil {
+			return err
+		}
+
+		res.Items[i] = batchItemDTO{Index: i}
+
+		if rejected != nil {
+			res.Items[i].Status = rejected.Status
+			res.Items[i].Message = rejected.Message
+			continue
+		}
+
+		messages = append(messages, message)
+		sendAts = append(sendAts, message.SendAt)
+		indexes = append(indexes, i)
+	}
+
+	ids := []string{}
+	if len(messages) > 0 {
+		var err error
+		if ids, err = sender.SendBatch(messages); err != nil {
+			return err
+		}
+	}
+
+	return x.batchAccepted(res, indexes, ids, sendAts)
+}
+
+// EmailBatch send many emails, each message is validated independently
+func (x *MessengerController) EmailBatch() error {
+
+	sender, rejected := channelSender(x, x.appService.EmailSender())
+	if rejected != nil {
+		return x.reject(rejected)
+	}
+
+	list, rejected := x.batchMessages()
+	if rejected != nil {
+		return x.reject(rejected)
+	}
+
+	res := batchAcceptedDTO{Items: make([]batchItemDTO, len(list))}
+	messages := []service.EmailMessage{}
+	sendAts := []time.Time{}
+	indexes := []int{}
+
+	for i := range list {
+
+		message, rejected, err := x.emailBatchMessage(&list[i])
+		if err != nil {
+			return err
+		}
+
+		res.Items[i] = batchItemDTO{Index: i}
+
+		if rejected != nil {
+			res.Items[i].Status = rejected.Status
+			res.Items[i].Message = rejected.Message
+			continue
+		}
+
+		messages = append(messages, message)
+		sendAts = append(sendAts, message.SendAt)
+		indexes = append(indexes, i)
+	}
+
+	ids := []string{}
+	if len(messages) > 0 {
+		var err error
+		if ids, err = sender.SendBatch(messages); err != nil {
+			return err
+		}
+	}
+
+	return x.batchAccepted(res, indexes, ids, sendAts)
+}
+
+// batchMessages bind batch, whole batch is rejected if empty or too large
+func (x *MessengerController) batchMessages() ([]messageDTO, *rejection) {
+
+	dto := &batchDTO{}
+	if err := x.webCtxt.Bind(dto); err != nil {
+		return nil, &rejection{HTTPStatus: http.StatusBadRequest, Status: "invalid_body", Message: err.Error()}
+	}
+
+	list := dto.messages()
+	maxSize := x.appService.Config().Messenger.BatchMaxSize
+
+	if len(list) == 0 {
+		return nil, &rejection{HTTPStatus: http.StatusBadRequest, Status: "empty_arg", Message: "argument is empty: messages"}
+	}
+
+	if maxSize > 0 && len(list) > maxSize {
+		return nil, &rejection{
+			HTTPStatus: http.StatusRequestEntityTooLarge,
+			Status:     "batch_too_large",
+			Message:    fmt.Sprintf("batch size %v exceeds %v", len(list), maxSize),
+		}
+	}
+
+	return list, nil
+}
+
+// batchAccepted write per item results, ids and send times are in order of accepted indexes
+func (x *MessengerController) batchAccepted(res batchAcceptedDTO, indexes []int, ids []string, sendAts []time.Time) error {
+
+	for i, index := range indexes {
+		res.Items[index].ID = ids[i]
+		res.Items[index].Status = service.OutboxStatusQueued
+
+		if !sendAts[i].IsZero() {
+			res.Items[index].Status = service.OutboxStatusScheduled
+			res.Items[index].SendAt = &sendAts[i]
+		}
+	}
+
+	res.Accepted = len(indexes)
+	res.Rejected = len(res.Items) - res.Accepted
+
+	return x.webCtxt.JSONPretty(http.StatusOK, res, "")
+}
+
+// batchContent rejection if message has no content
+func batchContent(dto *messageDTO) *rejection {
+
+	if dto.To == "" {
+		return &rejection{HTTPStatus: http.StatusBadRequest, Status: "empty_arg", Message: "argument is empty: to"}
+	}
+
+	if dto.Text == "" && dto.HTML == "" && dto.Template == "" {
+		return &rejection{HTTPStatus: http.StatusBadRequest, Status: "empty_arg", Message: "argument is empty: content"}
+	}
+
+	return nil
+}
+
+// smsBatchMessage sms of batch item, text or template
+func (x *MessengerController) smsBatchMessage(dto *messageDTO) (service.SmsMessage, *rejection, error) {
+
+	message := service.SmsMessage{}
+
+	if rejected := batchContent(dto); rejected != nil {
+		return message, rejected, nil
+	}
+
+	to, rejected, err := x.checkRecipient(service.ChannelSms, dto.To)
+	if rejected != nil || err != nil {
+		return message, rejected, err
+	}
+
+	sendAt, rejected := x.checkSendAt(dto)
+	if rejected != nil {
+		return message, rejected, nil
+	}
+
+	message.CreatedAt = time.Now()
+	message.To = to
+	message.Lang = dto.Lang
+	message.SendAt = sendAt
+	message.Text = dto.Text
+
+	if dto.Template != "" {
+		rendered, rejected := x.checkTemplate(service.ChannelSms, dto)
+		if rejected != nil {
+			return message, rejected, nil
+		}
+		message.Text = rendered.Body
+	}
+
+	return message, nil, nil
+}
+
+// emailBatchMessage email of batch item, html or template
+func (x *MessengerController) emailBatchMessage(dto *messageDTO) (service.EmailMessage, *rejection, error) {
+
+	message := service.EmailMessage{}
+
+	if rejected := batchContent(dto); rejected != nil {
+		return message, rejected, nil
+	}
+
+	to, rejected, err := x.checkRecipient(service.ChannelEmail, dto.To)
+	if rejected != nil || err != nil {
+		return message, rejected, err
+	}
+
+	sendAt, rejected := x.checkSendAt(dto)
+	if rejected != nil {
+		return message, rejected, nil
+	}
+
+	message.CreatedAt = time.Now()
+	message.To = to
+	message.Lang = dto.Lang
+	message.SendAt = sendAt
+	message.Subject = dto.Subject
+	message.HTML = dto.HTML
+	message.Text = dto.Text
+	message.Headers = dto.Headers
+
+	if dto.Template != "" {
+		rendered, rejected := x.checkTemplate(service.ChannelEmail, dto)
+		if rejected != nil {
+			return message, rejected, nil
+		}
+		message.HTML = rendered.Body
+		message.Text = ""
+		if rendered.Subject != "" {
+			message.Subject = rendered.Subject
+		}
+	}
+
+	rejected, err = x.checkEmailExtras(dto, &message)
+
+	return message, rejected, err
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"encoding/json"
+	"go-infra/internal/service"
+	"net/http"
+	"time"
+)
+
+// chatDTO chat room message, blocks and attachments are json only
+type chatDTO struct {
+	To          string                   `form:"to" json:"to"` // room name
+	Text        string                   `form:"text" json:"text"`
+	Blocks      json.RawMessage          `json:"blocks"`
+	Attachments []service.ChatAttachment `json:"attachments"`
+
+	SendAt   string `form:"send_at" json:"send_at"`
+	Timezone string `form:"timezone" json:"timezone"`
+	Delay    int    `form:"delay" json:"delay"`
+}
+
+// validate rejection of empty room or content, blocks must be json
+func (x chatDTO) validate() *rejection {
+
+	if x.To == "" {
+		return &rejection{HTTPStatus: http.StatusBadRequest, Status: "empty_arg", Message: "argument is empty: to"}
+	}
+
+	if x.Text == "" && len(x.Blocks) == 0 && len(x.Attachments) == 0 {
+		return &rejection{HTTPStatus: http.StatusBadRequest, Status: "empty_arg", Message: "argument is empty: content"}
+	}
+
+	if len(x.Blocks) > 0 && !json.Valid(x.Blocks) {
+		return &rejection{HTTPStatus: http.StatusBadRequest, Status: "invalid_blocks", Message: "blocks is not valid json"}
+	}
+
+	return nil
+}
+
+// ChatText send message to chat room by incoming webhook
+func (x *MessengerController) ChatText() error {
+
+	c := x.webCtxt
+
+	sender, rejected := channelSender(x, x.appService.ChatSender())
+	if rejected != nil {
+		return x.reject(rejected)
+	}
+
+	dto := &chatDTO{}
+	if err := c.Bind(dto); err != nil {
+		return x.reject(&rejection{HTTPStatus: http.StatusBadRequest, Status: "invalid_body", Message: err.Error()})
+	}
+
+	if rejected := dto.validate(); rejected != nil {
+		return x.reject(rejected)
+	}
+
+	scheduled := &messageDTO{To: dto.To, SendAt: dto.SendAt, Timezone: dto.Timezone, Delay: dto.Delay}
+
+	if invalid, err := x.invalidRecipient(service.ChannelChat, scheduled); invalid || err != nil {
+		return err
+	}
+
+	sendAt, invalid, err := x.sendAt(scheduled)
+	if invalid || err != nil {
+		return err
+	}
+
+	message := service.ChatMessage{}
+
+	message.CreatedAt = time.Now()
+	message.To = scheduled.To
+	message.Text = dto.Text
+	message.Blocks = dto.Blocks
+	message.Attachments = dto.Attachments
+	message.SendAt = sendAt
+
+	id, err := sender.Send(message)
+	if err != nil {
+		return err
+	}
+
+	return c.JSONPretty(http.StatusOK, messageAccepted(service.ChannelChat, sender.Name(), message.To, id, sendAt), "")
+}
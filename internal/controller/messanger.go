@@ -5,9 +5,17 @@ package controller
 // benchmark db http://127.0.0.1:30780/sys/api/messenger?service_code=email_passcode&to=test@example.com&passcode=123456&lang=en
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"go-infra/internal/service"
+	"go-infra/internal/util/utilredact"
+	"go-infra/internal/util/utilsmtp"
+	"go-infra/internal/util/utiltaskqueue"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -42,14 +50,98 @@ type emailPasscodeData struct {
 	Passcode string
 }
 
+// queueStatsDTO send queue of channel
+type queueStatsDTO struct {
+	Channel     string         `json:"channel"`
+	QueueSize   int            `json:"queue_size"`
+	WorkerCount int            `json:"worker_count"`
+	RetryCount  int            `json:"retry_count"`
+	Lanes       []laneStatsDTO `json:"lanes"`
+}
+
+// laneStatsDTO priority lane of send queue
+type laneStatsDTO struct {
+	Priority  string `json:"priority"`
+	QueueSize int    `json:"queue_size"`
+	MaxSize   int    `json:"max_size,omitempty"`
+	Weight    int    `json:"weight"`
+	Enqueued  int64  `json:"enqueued"`
+	Processed int64  `json:"processed"`
+}
+
+// messageAcceptedDTO acknowledgement of accepted message, message content is not echoed
+type messageAcceptedDTO struct {
+	ID      string     `json:"id"`
+	Status  string     `json:"status"`
+	Channel string     `json:"channel"`
+	To      string     `json:"to"` // masked
+	SendAt  *time.Time `json:"send_at,omitempty"`
+}
+
+// messageAccepted acknowledgement of queued or scheduled message
+func messageAccepted(kind string, channel string, to string, id string, sendAt time.Time) messageAcceptedDTO {
+
+	res := messageAcceptedDTO{ID: id, Status: service.OutboxStatusQueued, Channel: channel, To: maskRecipient(kind, to)}
+
+	if !sendAt.IsZero() {
+		res.Status = service.OutboxStatusScheduled
+		res.SendAt = &sendAt
+	}
+
+	return res
+}
+
+// maskRecipient partially masked phone or email
+func maskRecipient(channel string, to string) string {
+
+	switch channel {
+	case service.ChannelSms:
+		return utilredact.Phone(to)
+	case service.ChannelEmail:
+		return utilredact.Email(to)
+	case service.ChannelChat:
+		return to // room name
+	}
+
+	return utilredact.Mask
+}
+
 type messageDTO struct {
 	To       string `form:"to"`
 	Text     string `form:"text"`
 	HTML     string `form:"html"`
 	Passcode string `form:"passcode"`
 	Lang     string `form:"lang"`
+
+	// named template, data is json only
+	Template string         `form:"template" json:"template"`
+	Data     map[string]any `json:"data"`
+
+	// scheduled delivery, send_at is RFC 3339 or local time of timezone, delay in seconds
+	SendAt   string `form:"send_at" json:"send_at"`
+	Timezone string `form:"timezone" json:"timezone"`
+	Delay    int    `form:"delay" json:"delay"`
+
+	// email only
+	Subject     string            `form:"subject" json:"subject"`
+	Cc          []string          `form:"cc" json:"cc"`
+	Bcc         []string          `form:"bcc" json:"bcc"`
+	ReplyTo     string            `form:"reply_to" json:"reply_to"`
+	Headers     map[string]string `json:"headers"`
+	Attachments []attachmentDTO   `json:"attachments"`
+}
+
+// attachmentDTO email attachment, content in base64
+type attachmentDTO struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	ContentID   string `json:"content_id"` // inline image, <img src="cid:content_id">
+	Content     string `json:"content"`
 }
 
+// maxAttachmentsSize total size of decoded attachments
+const maxAttachmentsSize = 10 << 20
+
 func (x messageDTO) validate() any {
 
 	if x.To == "" {
@@ -69,6 +161,30 @@ func (x messageDTO) validate() any {
 	return nil
 }
 
+func (x messageDTO) validateTemplate() any {
+
+	if x.To == "" {
+		return map[string]string{
+			"status":  "empty_arg",
+			"message": "argument is empty: to",
+		}
+	}
+
+	if x.Template == "" {
+		return map[string]string{
+			"status":  "empty_arg",
+			"message": "argument is empty: template",
+		}
+	}
+
+	return nil
+}
+
+// maxAge seconds from config to message max age
+func maxAge(seconds int) int16 {
+	return int16(min(max(seconds, 0), math.MaxInt16)) //nolint:gosec
+}
+
 // SmsText send sms text
 func (x *MessengerController) SmsText() error {
 	/*
@@ -76,6 +192,12 @@ func (x *MessengerController) SmsText() error {
 	*/
 
 	c := x.webCtxt
+
+	sender, rejected := channelSender(x, x.appService.SmsSender())
+	if rejected != nil {
+		return x.reject(rejected)
+	}
+
 	dto := &messageDTO{}
 	err := c.Bind(dto)
 	if err != nil {
@@ -88,18 +210,28 @@ func (x *MessengerController) SmsText() error {
 
 	}
 
+	if invalid, err := x.invalidRecipient(service.ChannelSms, dto); invalid || err != nil {
+		return err
+	}
+
+	sendAt, invalid, err := x.sendAt(dto)
+	if invalid || err != nil {
+		return err
+	}
+
 	data := smsPasscodeData{}
 
 	data.Message.CreatedAt = time.Now()
 	data.Message.To = dto.To
 	data.Message.Text = dto.Text
+	data.Message.SendAt = sendAt
 
-	err = x.appService.SmsSender().Send(data.Message)
+	id, err := sender.Send(data.Message)
 	if err != nil {
 		return err
 	}
 
-	return c.String(http.StatusOK, data.Message.Text)
+	return c.JSONPretty(http.StatusOK, messageAccepted(service.ChannelSms, sender.Name(), data.Message.To, id, sendAt), "")
 
 }
 
@@ -107,6 +239,12 @@ func (x *MessengerController) SmsText() error {
 func (x *MessengerController) SmsPasscode() error {
 
 	c := x.webCtxt
+
+	sender, rejected := channelSender(x, x.appService.SmsSender())
+	if rejected != nil {
+		return x.reject(rejected)
+	}
+
 	dto := &messageDTO{}
 	err := c.Bind(dto)
 	if err != nil {
@@ -119,24 +257,22 @@ func (x *MessengerController) SmsPasscode() error {
 
 	}
 
-	data := smsPasscodeData{}
-	data.Message.MaxAge = 30 // seconds
-	data.Message.CreatedAt = time.Now()
-	data.Message.To = dto.To
-	data.Passcode = dto.Passcode
-	data.Message.Lang = dto.Lang
+	if invalid, err := x.invalidRecipient(service.ChannelSms, dto); invalid || err != nil {
+		return err
+	}
 
-	data.Message.Text = fmt.Sprintf("%s: %s",
-		x.appService.UserLang(data.Message.Lang).Lang("Secret code"),
-		data.Passcode,
-	)
+	if throttled, err := x.throttled(service.ChannelSms, dto.To); throttled || err != nil {
+		return err
+	}
 
-	err = x.appService.SmsSender().Send(data.Message)
+	data := x.smsPasscodeData(dto.To, dto.Lang, dto.Passcode)
+
+	id, err := sender.Send(data.Message)
 	if err != nil {
 		return err
 	}
 
-	return c.String(http.StatusOK, data.Message.Text)
+	return c.JSONPretty(http.StatusOK, messageAccepted(service.ChannelSms, sender.Name(), data.Message.To, id, time.Time{}), "")
 
 }
 
@@ -144,6 +280,12 @@ func (x *MessengerController) SmsPasscode() error {
 func (x *MessengerController) EmailHTML() error {
 
 	c := x.webCtxt
+
+	sender, rejected := channelSender(x, x.appService.EmailSender())
+	if rejected != nil {
+		return x.reject(rejected)
+	}
+
 	dto := &messageDTO{}
 	err := c.Bind(dto)
 	if err != nil {
@@ -156,27 +298,48 @@ func (x *MessengerController) EmailHTML() error {
 
 	}
 
+	if invalid, err := x.invalidRecipient(service.ChannelEmail, dto); invalid || err != nil {
+		return err
+	}
+
+	sendAt, invalid, err := x.sendAt(dto)
+	if invalid || err != nil {
+		return err
+	}
+
 	data := emailPasscodeData{}
 	data.Message.CreatedAt = time.Now()
 	data.Message.From = ""
 	data.Message.To = dto.To
+	data.Message.Subject = dto.Subject
 	data.Message.HTML = dto.HTML
+	data.Message.Text = dto.Text
+	data.Message.Headers = dto.Headers
+	data.Message.SendAt = sendAt
+
+	if invalid, err := x.emailExtras(dto, &data.Message); invalid || err != nil {
+		return err
+	}
 
-	err = x.appService.EmailSender().Send(data.Message)
+	id, err := sender.Send(data.Message)
 	if err != nil {
 		return err
 	}
 
-	return c.HTML(http.StatusOK, data.Message.HTML)
+	return c.JSONPretty(http.StatusOK, messageAccepted(service.ChannelEmail, sender.Name(), data.Message.To, id, sendAt), "")
 
 }
 
 // EmailPasscode send email secret code
 func (x *MessengerController) EmailPasscode() error {
 
-	appConfig := x.appService.Config()
-
 	c := x.webCtxt
+
+	sender, rejected := channelSender(x, x.appService.EmailSender())
+	if rejected != nil {
+		return x.reject(rejected)
+	}
+
 	dto := &messageDTO{}
 	err := c.Bind(dto)
 	if err != nil {
@@ -189,37 +352,677 @@ func (x *MessengerController) EmailPasscode() error {
 
 	}
 
+	if invalid, err := x.invalidRecipient(service.ChannelEmail, dto); invalid || err != nil {
+		return err
+	}
+
+	if throttled, err := x.throttled(service.ChannelEmail, dto.To); throttled || err != nil {
+		return err
+	}
+
+	data, err := x.emailPasscodeData(dto.To, dto.Lang, dto.Passcode)
+	if err != nil {
+		return err
+	}
+
+	id, err := sender.Send(data.Message)
+	if err != nil {
+		return err
+	}
+
+	return c.JSONPretty(http.StatusOK, messageAccepted(service.ChannelEmail, sender.Name(), data.Message.To, id, time.Time{}), "")
+
+}
+
+// SmsTemplate send sms rendered by named template
+func (x *MessengerController) SmsTemplate() error {
+
+	c := x.webCtxt
+
+	sender, rejected := channelSender(x, x.appService.SmsSender())
+	if rejected != nil {
+		return x.reject(rejected)
+	}
+
+	dto := &messageDTO{}
+	err := c.Bind(dto)
+	if err != nil {
+		return err
+	}
+
+	if resp := dto.validateTemplate(); resp != nil {
+
+		return c.JSONPretty(http.StatusBadRequest, resp, "")
+
+	}
+
+	if invalid, err := x.invalidRecipient(service.ChannelSms, dto); invalid || err != nil {
+		return err
+	}
+
+	sendAt, invalid, err := x.sendAt(dto)
+	if invalid || err != nil {
+		return err
+	}
+
+	rendered, failed, err := x.renderTemplate(service.ChannelSms, dto)
+	if failed || err != nil {
+		return err
+	}
+
+	data := smsPasscodeData{}
+	data.Message.CreatedAt = time.Now()
+	data.Message.To = dto.To
+	data.Message.Lang = dto.Lang
+	data.Message.Text = rendered.Body
+	data.Message.SendAt = sendAt
+
+	id, err := sender.Send(data.Message)
+	if err != nil {
+		return err
+	}
+
+	return c.JSONPretty(http.StatusOK, messageAccepted(service.ChannelSms, sender.Name(), data.Message.To, id, sendAt), "")
+
+}
+
+// EmailTemplate send email rendered by named template, subject from template or request
+func (x *MessengerController) EmailTemplate() error {
+
+	c := x.webCtxt
+
+	sender, rejected := channelSender(x, x.appService.EmailSender())
+	if rejected != nil {
+		return x.reject(rejected)
+	}
+
+	dto := &messageDTO{}
+	err := c.Bind(dto)
+	if err != nil {
+		return err
+	}
+
+	if resp := dto.validateTemplate(); resp != nil {
+
+		return c.JSONPretty(http.StatusBadRequest, resp, "")
+
+	}
+
+	if invalid, err := x.invalidRecipient(service.ChannelEmail, dto); invalid || err != nil {
+		return err
+	}
+
+	sendAt, invalid, err := x.sendAt(dto)
+	if invalid || err != nil {
+		return err
+	}
+
+	rendered, failed, err := x.renderTemplate(service.ChannelEmail, dto)
+	if failed || err != nil {
+		return err
+	}
+
 	data := emailPasscodeData{}
 	data.Message.CreatedAt = time.Now()
 	data.Message.From = ""
 	data.Message.To = dto.To
-	data.Passcode = dto.Passcode
 	data.Message.Lang = dto.Lang
+	data.Message.Subject = dto.Subject
+	data.Message.HTML = rendered.Body
+	data.Message.Headers = dto.Headers
+	data.Message.SendAt = sendAt
+
+	if rendered.Subject != "" {
+		data.Message.Subject = rendered.Subject
+	}
+
+	if invalid, err := x.emailExtras(dto, &data.Message); invalid || err != nil {
+		return err
+	}
+
+	id, err := sender.Send(data.Message)
+	if err != nil {
+		return err
+	}
+
+	return c.JSONPretty(http.StatusOK, messageAccepted(service.ChannelEmail, sender.Name(), data.Message.To, id, sendAt), "")
+
+}
+
+// sendAt scheduled send time of dto, zero if now, 400 is written if not valid
+func (x *MessengerController) sendAt(dto *messageDTO) (time.Time, bool, error) {
+
+	res, rejected := x.checkSendAt(dto)
+	if rejected != nil {
+		return res, true, x.reject(rejected)
+	}
+
+	return res, false, nil
+}
+
+// localTimeLayouts send_at without offset, time of timezone
+var localTimeLayouts = []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04"}
+
+// checkSendAt send time by send_at or delay, zero if not in future
+func (x *MessengerController) checkSendAt(dto *messageDTO) (time.Time, *rejection) {
+
+	invalid := func(message string) *rejection {
+		return &rejection{HTTPStatus: http.StatusBadRequest, Status: "invalid_send_at", Message: message}
+	}
+
+	now := time.Now()
+	res := time.Time{}
+
+	switch {
+	case dto.SendAt != "" && dto.Delay != 0:
+		return res, invalid("send_at and delay are exclusive")
+
+	case dto.Delay < 0:
+		return res, invalid("delay is negative")
+
+	case dto.Delay > 0:
+		res = now.Add(time.Duration(dto.Delay) * time.Second)
+
+	case dto.SendAt != "":
+
+		loc := time.UTC
+		if dto.Timezone != "" {
+			var err error
+			if loc, err = time.LoadLocation(dto.Timezone); err != nil {
+				return res, invalid(fmt.Sprintf("timezone: %v", err))
+			}
+		}
+
+		var err error
+		if res, err = time.Parse(time.RFC3339, dto.SendAt); err != nil {
+			for _, layout := range localTimeLayouts {
+				if res, err = time.ParseInLocation(layout, dto.SendAt, loc); err == nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return res, invalid("send_at is not RFC 3339 or local time 2006-01-02T15:04:05")
+		}
+	}
+
+	maxDelay := time.Duration(x.appService.Config().Messenger.ScheduleMaxDelay) * time.Second
+	if maxDelay > 0 && res.Sub(now) > maxDelay {
+		return res, invalid(fmt.Sprintf("send_at is later than %v", maxDelay))
+	}
+
+	if !res.After(now) {
+		return time.Time{}, nil
+	}
+
+	return res.UTC(), nil
+}
+
+// rejection message is not valid, Status is error code for clients
+type rejection struct {
+	HTTPStatus int
+	Status     string
+	Message    string
+}
+
+// reject write rejection response
+func (x *MessengerController) reject(r *rejection) error {
+	return x.webCtxt.JSONPretty(r.HTTPStatus, map[string]string{
+		"status":  r.Status,
+		"message": r.Message,
+	}, "")
+}
+
+// channelSender channel of :channel param resolved by registry, default channel of type without param,
+// 404 if channel not exists or is of other type
+func channelSender[T any](x *MessengerController, def service.MessageChannel[T]) (service.MessageChannel[T], *rejection) {
+
+	name := x.webCtxt.Param("channel")
+	if name == "" {
+		return def, nil
+	}
+
+	res, ok := service.ChannelOf[T](x.appService.Channels(), name)
+	if !ok {
+		return nil, &rejection{HTTPStatus: http.StatusNotFound, Status: "not_found", Message: "channel not exists: " + name}
+	}
+
+	return res, nil
+}
+
+// renderTemplate render dto.Template with user lang, 404 or 400 is written on error
+func (x *MessengerController) renderTemplate(channel string, dto *messageDTO) (service.RenderedTemplate, bool, error) {
+
+	rendered, rejected := x.checkTemplate(channel, dto)
+	if rejected != nil {
+		return rendered, true, x.reject(rejected)
+	}
+
+	return rendered, false, nil
+}
+
+// checkTemplate render dto.Template with user lang
+func (x *MessengerController) checkTemplate(channel string, dto *messageDTO) (service.RenderedTemplate, *rejection) {
+
+	userLang := x.appService.UserLang(dto.Lang)
+
+	rendered, err := x.appService.Templates().Render(channel, dto.Template, userLang.LangCode(),
+		service.NewTemplateMessageData(userLang, x.appService.Config().Title, dto.Data))
+
+	if errors.Is(err, service.ErrTemplateNotFound) {
+		return rendered, &rejection{HTTPStatus: http.StatusNotFound, Status: "template_not_found", Message: err.Error()}
+	}
+
+	if err != nil {
+		return rendered, &rejection{HTTPStatus: http.StatusBadRequest, Status: "template_error", Message: err.Error()}
+	}
+
+	return rendered, nil
+}
+
+// invalidRecipient normalize dto.To, 400 with error code is written if recipient is not valid
+func (x *MessengerController) invalidRecipient(channel string, dto *messageDTO) (bool, error) {
+
+	to, rejected, err := x.checkRecipient(channel, dto.To)
+	if rejected != nil {
+		return true, x.reject(rejected)
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	dto.To = to
+
+	return false, nil
+}
+
+// checkRecipient normalized recipient, suppressed one is rejected with 422
+func (x *MessengerController) checkRecipient(channel string, to string) (string, *rejection, error) {
+
+	res, err := x.appService.Recipients().Normalize(channel, to)
+
+	var recipientErr *service.RecipientError
+	if errors.As(err, &recipientErr) {
+		return "", &rejection{HTTPStatus: http.StatusBadRequest, Status: recipientErr.Code, Message: recipientErr.Message}, nil
+	}
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = x.appService.Suppressions().Check(channel, []string{res})
+
+	var suppressedErr *service.SuppressedError
+	if errors.As(err, &suppressedErr) {
+		return "", &rejection{HTTPStatus: http.StatusUnprocessableEntity, Status: "suppressed", Message: suppressedErr.Error()}, nil
+	}
+
+	return res, nil, err
+}
+
+// emailExtras validate and copy cc, bcc, reply-to and attachments, 400 is written if not valid
+func (x *MessengerController) emailExtras(dto *messageDTO, message *service.EmailMessage) (bool, error) {
+
+	rejected, err := x.checkEmailExtras(dto, message)
+	if rejected != nil {
+		return true, x.reject(rejected)
+	}
+
+	return false, err
+}
+
+// checkEmailExtras validate and copy cc, bcc, reply-to and attachments
+func (x *MessengerController) checkEmailExtras(dto *messageDTO, message *service.EmailMessage) (*rejection, error) {
+
+	invalid := func(status string, message string) *rejection {
+		return &rejection{HTTPStatus: http.StatusBadRequest, Status: status, Message: message}
+	}
+
+	if err := utilsmtp.CheckHeaders(dto.Headers); err != nil {
+		return invalid("invalid_header", err.Error()), nil
+	}
+
+	for _, list := range []struct {
+		src []string
+		dst *[]string
+	}{{dto.Cc, &message.Cc}, {dto.Bcc, &message.Bcc}} {
+		for _, v := range list.src {
+			to, rejected, err := x.checkRecipient(service.ChannelEmail, v)
+			if rejected != nil || err != nil {
+				return rejected, err
+			}
+			*list.dst = append(*list.dst, to)
+		}
+	}
+
+	if dto.ReplyTo != "" {
+		replyTo, err := x.appService.Recipients().Normalize(service.ChannelEmail, dto.ReplyTo)
+		if err != nil {
+			return invalid("invalid_reply_to", err.Error()), nil
+		}
+		message.ReplyTo = replyTo
+	}
+
+	size := 0
+
+	for _, itm := range dto.Attachments {
+
+		content, err := base64.StdEncoding.DecodeString(itm.Content)
+		size += len(content)
+
+		if err != nil || itm.Filename == "" || size > maxAttachmentsSize {
+			return invalid("invalid_attachment",
+				fmt.Sprintf("attachment %q: filename, base64 content, total size up to %v bytes", itm.Filename, maxAttachmentsSize)), nil
+		}
+
+		message.Attachments = append(message.Attachments, service.EmailAttachment{
+			Filename:    itm.Filename,
+			ContentType: itm.ContentType,
+			ContentID:   itm.ContentID,
+			Content:     content,
+		})
+	}
+
+	return nil, nil
+}
+
+// throttled check abuse limits of passcode request, 429 with Retry-After is written if limit is reached
+func (x *MessengerController) throttled(channel string, to string) (bool, error) {
+
+	c := x.webCtxt
+
+	err := x.appService.Throttler().Allow(service.ThrottleRequest{
+		Channel: channel,
+		To:      to,
+		IP:      c.RealIP(),
+	})
+
+	var throttledErr *service.ThrottledError
+	if !errors.As(err, &throttledErr) {
+		return false, err
+	}
+
+	retryAfter := int(math.Ceil(throttledErr.RetryAfter.Seconds()))
+
+	c.Response().Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+
+	return true, c.JSONPretty(http.StatusTooManyRequests, map[string]string{
+		"status":  "throttled",
+		"message": err.Error(),
+	}, "")
+}
+
+// smsPasscodeData localized passcode sms
+func (x *MessengerController) smsPasscodeData(to string, lang string, passcode string) smsPasscodeData {
+
+	appConfig := x.appService.Config()
+
+	data := smsPasscodeData{}
+	data.Message.MaxAge = maxAge(appConfig.Messenger.SmsPasscodeMaxAge)
+	data.Message.CreatedAt = time.Now()
+	data.Message.To = to
+	data.Passcode = passcode
+	data.Message.Lang = lang
+	data.Message.Secrets = []string{passcode}
+	data.Message.Priority = utiltaskqueue.PriorityHigh
+
+	data.Message.Text = fmt.Sprintf("%s: %s",
+		x.appService.UserLang(data.Message.Lang).Lang("Secret code"),
+		data.Passcode,
+	)
+
+	return data
+}
+
+// emailPasscodeData localized passcode email by template
+func (x *MessengerController) emailPasscodeData(to string, lang string, passcode string) (emailPasscodeData, error) {
+
+	appConfig := x.appService.Config()
+
+	data := emailPasscodeData{}
+	data.Message.MaxAge = maxAge(appConfig.Messenger.EmailPasscodeMaxAge)
+	data.Message.CreatedAt = time.Now()
+	data.Message.From = ""
+	data.Message.To = to
+	data.Passcode = passcode
+	data.Message.Lang = lang
+	data.Message.Secrets = []string{passcode}
+	data.Message.Priority = utiltaskqueue.PriorityHigh
 
 	userLang := x.appService.UserLang(data.Message.Lang)
 	labelPasscode := userLang.Lang("Secret code")
 	data.Message.Subject = fmt.Sprintf("%v - %v", labelPasscode, appConfig.Title)
 
-	bu := strings.Builder{}
-	err = TemplateEmailPasscode().Execute(&bu, templateEmailPasscodeData{
-		LangCode:      userLang.LangCode(),
-		AppTitle:      appConfig.Title,
-		LabelPasscode: labelPasscode,
-		Passcode:      data.Passcode,
-		Subject:       data.Message.Subject,
-	})
+	rendered, err := x.appService.Templates().Render(service.ChannelEmail, service.TemplateEmailPasscode, userLang.LangCode(),
+		service.EmailPasscodeTemplateData{
+			LangCode:      userLang.LangCode(),
+			AppTitle:      appConfig.Title,
+			LabelPasscode: labelPasscode,
+			Passcode:      data.Passcode,
+			Subject:       data.Message.Subject,
+		})
+
+	if err != nil {
+		return data, err
+	}
+
+	data.Message.HTML = rendered.Body
+
+	return data, nil
+}
+
+// MessageStatus get message status by id
+func (x *MessengerController) MessageStatus() error {
+
+	c := x.webCtxt
+
+	res, err := x.appService.Messages().Status(c.Param("id"))
+
+	if errors.Is(err, service.ErrMessageNotFound) {
+		return c.JSONPretty(http.StatusNotFound, map[string]string{
+			"status":  "not_found",
+			"message": err.Error(),
+		}, "")
+	}
 
 	if err != nil {
 		return err
 	}
 
-	data.Message.HTML = bu.String()
+	return c.JSONPretty(http.StatusOK, res, "")
+}
+
+// CancelMessage cancel scheduled message before send time
+func (x *MessengerController) CancelMessage() error {
+
+	c := x.webCtxt
+
+	err := x.appService.Messages().Cancel(c.Param("id"))
+
+	if errors.Is(err, service.ErrMessageNotFound) {
+		return c.JSONPretty(http.StatusNotFound, map[string]string{
+			"status":  "not_found",
+			"message": err.Error(),
+		}, "")
+	}
+
+	if errors.Is(err, service.ErrMessageNotScheduled) {
+		return c.JSONPretty(http.StatusConflict, map[string]string{
+			"status":  "not_scheduled",
+			"message": err.Error(),
+		}, "")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSONPretty(http.StatusOK, map[string]string{
+		"status":  service.OutboxStatusCanceled,
+		"message": "message is canceled",
+	}, "")
+}
+
+// Spend current day and month spend of gateways
+func (x *MessengerController) Spend() error {
+
+	res, err := x.appService.Spend().Report()
+	if err != nil {
+		return err
+	}
+
+	return x.webCtxt.JSONPretty(http.StatusOK, res, "")
+}
+
+// Queues send queue of channels by priority lanes
+func (x *MessengerController) Queues() error {
+
+	channels := x.appService.Channels()
+	res := []queueStatsDTO{}
+
+	for _, name := range channels.Names() {
+
+		channel, _ := channels.Channel(name)
+		stats := channel.Stats()
+
+		itm := queueStatsDTO{
+			Channel:     name,
+			QueueSize:   stats.QueueSize,
+			WorkerCount: stats.WorkerCount,
+			RetryCount:  stats.RetryCount,
+			Lanes:       []laneStatsDTO{},
+		}
+
+		for _, lane := range stats.Lanes {
+			itm.Lanes = append(itm.Lanes, laneStatsDTO{
+				Priority:  service.PriorityName(lane.Priority),
+				QueueSize: lane.QueueSize,
+				MaxSize:   lane.MaxSize,
+				Weight:    lane.Weight,
+				Enqueued:  lane.Enqueued,
+				Processed: lane.Processed,
+			})
+		}
+
+		res = append(res, itm)
+	}
+
+	return x.webCtxt.JSONPretty(http.StatusOK, res, "")
+}
+
+// DeadLetters list messages failed after all attempts
+func (x *MessengerController) DeadLetters() error {
+
+	c := x.webCtxt
+
+	channel, ok := x.appService.Channels().Channel(c.Param("channel"))
+	if !ok {
+		return c.JSONPretty(http.StatusNotFound, map[string]string{
+			"status":  "not_found",
+			"message": "channel not exists",
+		}, "")
+	}
+
+	return c.JSONPretty(http.StatusOK, channel.DeadLetters(), "")
+}
+
+// RequeueDeadLetter send failed message again
+func (x *MessengerController) RequeueDeadLetter() error {
+
+	c := x.webCtxt
+	id := c.Param("id")
+
+	channel, ok := x.appService.Channels().Channel(c.Param("channel"))
+	if !ok {
+		return c.JSONPretty(http.StatusNotFound, map[string]string{
+			"status":  "not_found",
+			"message": "channel not exists",
+		}, "")
+	}
+
+	err := channel.Requeue(id)
+
+	switch {
+	case errors.Is(err, utiltaskqueue.ErrDeadLetterNotFound):
+		return x.reject(&rejection{HTTPStatus: http.StatusNotFound, Status: "not_found", Message: err.Error()})
+	case err != nil:
+		// queue is full or stopped, dead letter is kept
+		return x.reject(&rejection{HTTPStatus: http.StatusServiceUnavailable, Status: "queue_rejected", Message: err.Error()})
+	}
+
+	return c.JSONPretty(http.StatusOK, map[string]string{
+		"status": "queued",
+		"id":     id,
+	}, "")
+}
+
+// DeliveryReceipt webhook of gateway with delivery receipts, json (object or list) or form body
+func (x *MessengerController) DeliveryReceipt() error {
+
+	c := x.webCtxt
+
+	token := c.QueryParam("token")
+	if token == "" {
+		token = c.Request().Header.Get("X-Webhook-Token")
+	}
+
+	data, err := receiptData(c)
+	if err != nil {
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{
+			"status":  "error",
+			"message": err.Error(),
+		}, "")
+	}
+
+	res, err := x.appService.Messages().ApplyReceipts(c.Param("channel"), c.Param("gateway"), token, data)
+
+	if errors.Is(err, service.ErrReceiptForbidden) {
+		return c.JSONPretty(http.StatusForbidden, map[string]string{
+			"status":  "forbidden",
+			"message": err.Error(),
+		}, "")
+	}
 
-	err = x.appService.EmailSender().Send(data.Message)
 	if err != nil {
 		return err
 	}
 
-	return c.HTML(http.StatusOK, data.Message.HTML)
+	return c.JSONPretty(http.StatusOK, map[string]any{
+		"status":    "ok",
+		"updated":   res.Updated,
+		"unmatched": res.Unmatched,
+	}, "")
+}
+
+// receiptData decoded json body or form values, first value of form key
+func receiptData(c echo.Context) (any, error) {
+
+	req := c.Request()
+
+	if strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		// numbers as json.Number, provider ids above 2^53 are kept exact
+		dec := json.NewDecoder(req.Body)
+		dec.UseNumber()
+
+		var data any
+		if err := dec.Decode(&data); err != nil {
+			return nil, fmt.Errorf("error json body: %v", err)
+		}
+		return data, nil
+	}
+
+	form, err := c.FormParams()
+	if err != nil {
+		return nil, fmt.Errorf("error form body: %v", err)
+	}
+
+	data := map[string]any{}
+	for k, v := range form {
+		if len(v) > 0 {
+			data[k] = v[0]
+		}
+	}
 
+	return data, nil
 }
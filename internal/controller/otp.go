@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"errors"
+	"go-infra/internal/service"
+	"net/http"
+	"time"
+)
+
+// otpIssuedDTO response on issued passcode, code itself is not returned
+type otpIssuedDTO struct {
+	ID        string    `json:"id"`
+	MessageID string    `json:"message_id"`
+	Status    string    `json:"status"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type otpVerifyDTO struct {
+	ID      string `form:"id"`
+	Channel string `form:"channel"`
+	To      string `form:"to"`
+	Code    string `form:"code"`
+}
+
+// OTPIssue generate passcode and send it by channel
+func (x *MessengerController) OTPIssue() error {
+
+	c := x.webCtxt
+	dto := &messageDTO{}
+	err := c.Bind(dto)
+	if err != nil {
+		return err
+	}
+
+	if dto.To == "" {
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{
+			"status":  "empty_arg",
+			"message": "argument is empty: to",
+		}, "")
+	}
+
+	channel := c.Param("channel")
+
+	if channel != service.ChannelSms && channel != service.ChannelEmail {
+		return c.JSONPretty(http.StatusNotFound, map[string]string{
+			"status":  "not_found",
+			"message": "channel not exists",
+		}, "")
+	}
+
+	if invalid, err := x.invalidRecipient(channel, dto); invalid || err != nil {
+		return err
+	}
+
+	if throttled, err := x.throttled(channel, dto.To); throttled || err != nil {
+		return err
+	}
+
+	var id string
+
+	challenge, err := x.appService.OTP().Issue(channel, dto.To, func(code string) error {
+
+		var err error
+
+		switch channel {
+		case service.ChannelSms:
+			data := x.smsPasscodeData(dto.To, dto.Lang, code)
+			id, err = x.appService.SmsSender().Send(data.Message)
+		case service.ChannelEmail:
+			var data emailPasscodeData
+			data, err = x.emailPasscodeData(dto.To, dto.Lang, code)
+			if err == nil {
+				id, err = x.appService.EmailSender().Send(data.Message)
+			}
+		}
+
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSONPretty(http.StatusOK, otpIssuedDTO{
+		ID:        challenge.ID,
+		MessageID: id,
+		Status:    service.OutboxStatusQueued,
+		ExpiresAt: challenge.ExpiresAt,
+	}, "")
+}
+
+// OTPVerify check passcode by id or by channel and recipient
+func (x *MessengerController) OTPVerify() error {
+
+	c := x.webCtxt
+	dto := &otpVerifyDTO{}
+	err := c.Bind(dto)
+	if err != nil {
+		return err
+	}
+
+	if dto.Code == "" {
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{
+			"status":  "empty_arg",
+			"message": "argument is empty: code",
+		}, "")
+	}
+
+	to := dto.To
+	if normalized, err := x.appService.Recipients().Normalize(dto.Channel, to); err == nil {
+		to = normalized // same recipient as on issue
+	}
+
+	err = x.appService.OTP().Verify(service.OTPVerify{
+		ID:      dto.ID,
+		Channel: dto.Channel,
+		To:      to,
+		Code:    dto.Code,
+	})
+
+	var status int
+	var code string
+
+	switch {
+	case err == nil:
+		return c.JSONPretty(http.StatusOK, map[string]string{"status": "ok"}, "")
+	case errors.Is(err, service.ErrOTPInvalid):
+		status, code = http.StatusBadRequest, "invalid"
+	case errors.Is(err, service.ErrOTPNotFound):
+		status, code = http.StatusNotFound, "not_found"
+	case errors.Is(err, service.ErrOTPExpired):
+		status, code = http.StatusGone, "expired"
+	case errors.Is(err, service.ErrOTPUsed):
+		status, code = http.StatusGone, "used"
+	case errors.Is(err, service.ErrOTPAttemptsExceeded):
+		status, code = http.StatusTooManyRequests, "attempts_exceeded"
+	default:
+		return err
+	}
+
+	return c.JSONPretty(status, map[string]string{
+		"status":  code,
+		"message": err.Error(),
+	}, "")
+}
@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"go-infra/internal/service"
+	"net/http"
+	"strconv"
+)
+
+// SandboxMessages list messages captured by sandbox gateways, latest first, by channel and recipient
+func (x *MessengerController) SandboxMessages() error {
+
+	c := x.webCtxt
+
+	filter := service.SandboxFilter{Channel: c.QueryParam("channel"), To: c.QueryParam("to")}
+	filter.Limit, _ = strconv.Atoi(c.QueryParam("limit"))
+
+	if filter.Channel != "" && filter.To != "" {
+		if to, err := x.appService.Recipients().Normalize(filter.Channel, filter.To); err == nil {
+			filter.To = to // as stored by send
+		}
+	}
+
+	return c.JSONPretty(http.StatusOK, x.appService.Sandbox().List(filter), "")
+}
+
+// SandboxMessage captured message by its id or message id
+func (x *MessengerController) SandboxMessage() error {
+
+	c := x.webCtxt
+
+	res, ok := x.appService.Sandbox().Get(c.Param("id"))
+	if !ok {
+		return c.JSONPretty(http.StatusNotFound, map[string]string{
+			"status":  "not_found",
+			"message": "captured message not exists",
+		}, "")
+	}
+
+	return c.JSONPretty(http.StatusOK, res, "")
+}
+
+// ClearSandbox drop captured messages of channel or all
+func (x *MessengerController) ClearSandbox() error {
+
+	c := x.webCtxt
+
+	count := x.appService.Sandbox().Clear(c.QueryParam("channel"))
+
+	return c.JSONPretty(http.StatusOK, map[string]any{
+		"status":  "cleared",
+		"cleared": count,
+	}, "")
+}
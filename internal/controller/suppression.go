@@ -0,0 +1,262 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"go-infra/internal/service"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// suppression list limits
+const (
+	suppressionListLimit     = 100
+	suppressionListMaxLimit  = 1000
+	suppressionImportMaxSize = 10000
+)
+
+// suppressionDTO suppression of recipient, expires_at is RFC 3339, permanent if empty
+type suppressionDTO struct {
+	Channel   string     `json:"channel"`
+	Recipient string     `json:"recipient"`
+	Reason    string     `json:"reason"`
+	Source    string     `json:"source"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// suppressionImportDTO items, empty fields of item are taken from shared ones
+type suppressionImportDTO struct {
+	Channel   string           `json:"channel"`
+	Reason    string           `json:"reason"`
+	Source    string           `json:"source"`
+	ExpiresAt *time.Time       `json:"expires_at"`
+	Items     []suppressionDTO `json:"items"`
+}
+
+// suppressionImportedDTO response on import, rejected items only
+type suppressionImportedDTO struct {
+	Imported int            `json:"imported"`
+	Rejected int            `json:"rejected"`
+	Items    []batchItemDTO `json:"items"`
+}
+
+// checkSuppression suppression with normalized recipient, source is defaulted
+func (x *MessengerController) checkSuppression(dto suppressionDTO, source string) (service.Suppression, *rejection, error) {
+
+	res := service.Suppression{
+		Channel:   dto.Channel,
+		Recipient: dto.Recipient,
+		Reason:    dto.Reason,
+		Source:    dto.Source,
+		ExpiresAt: dto.ExpiresAt,
+	}
+
+	if res.Source == "" {
+		res.Source = source
+	}
+
+	if err := service.CheckSuppression(res); err != nil {
+		return res, &rejection{HTTPStatus: http.StatusBadRequest, Status: "invalid_suppression", Message: err.Error()}, nil
+	}
+
+	to, err := x.appService.Recipients().Normalize(res.Channel, res.Recipient)
+
+	var recipientErr *service.RecipientError
+	if errors.As(err, &recipientErr) {
+		return res, &rejection{HTTPStatus: http.StatusBadRequest, Status: recipientErr.Code, Message: recipientErr.Message}, nil
+	}
+
+	res.Recipient = to
+
+	return res, nil, err
+}
+
+// suppressionKey channel and normalized recipient of path, recipient is path escaped
+func (x *MessengerController) suppressionKey() (string, string, *rejection) {
+
+	c := x.webCtxt
+	channel := c.Param("channel")
+
+	recipient, err := url.PathUnescape(c.Param("recipient"))
+	if err != nil {
+		return "", "", &rejection{HTTPStatus: http.StatusBadRequest, Status: "invalid_arg", Message: err.Error()}
+	}
+
+	to, err := x.appService.Recipients().Normalize(channel, recipient)
+	if err != nil {
+		to = recipient // stored as is, lookup must not fail on config changes
+	}
+
+	return channel, to, nil
+}
+
+// Suppressions list active suppressions by optional channel and reason
+func (x *MessengerController) Suppressions() error {
+
+	c := x.webCtxt
+
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 {
+		limit = suppressionListLimit
+	}
+	limit = min(limit, suppressionListMaxLimit)
+
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+
+	res, err := x.appService.Suppressions().List(c.QueryParam("channel"), c.QueryParam("reason"), limit, max(offset, 0))
+	if err != nil {
+		return err
+	}
+
+	return c.JSONPretty(http.StatusOK, res, "")
+}
+
+// Suppression active suppression of recipient
+func (x *MessengerController) Suppression() error {
+
+	c := x.webCtxt
+
+	channel, recipient, rejected := x.suppressionKey()
+	if rejected != nil {
+		return x.reject(rejected)
+	}
+
+	res, err := x.appService.Suppressions().Get(channel, recipient)
+
+	if errors.Is(err, service.ErrSuppressionNotFound) {
+		return c.JSONPretty(http.StatusNotFound, map[string]string{
+			"status":  "not_found",
+			"message": err.Error(),
+		}, "")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSONPretty(http.StatusOK, res, "")
+}
+
+// AddSuppression add or replace suppression of recipient
+func (x *MessengerController) AddSuppression() error {
+
+	c := x.webCtxt
+	dto := suppressionDTO{}
+	if err := c.Bind(&dto); err != nil {
+		return err
+	}
+
+	item, rejected, err := x.checkSuppression(dto, "api")
+	if rejected != nil {
+		return x.reject(rejected)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := x.appService.Suppressions().Put(item); err != nil {
+		return err
+	}
+
+	return c.JSONPretty(http.StatusOK, map[string]string{
+		"status":    "suppressed",
+		"channel":   item.Channel,
+		"recipient": item.Recipient,
+	}, "")
+}
+
+// DeleteSuppression allow messages to recipient again
+func (x *MessengerController) DeleteSuppression() error {
+
+	c := x.webCtxt
+
+	channel, recipient, rejected := x.suppressionKey()
+	if rejected != nil {
+		return x.reject(rejected)
+	}
+
+	err := x.appService.Suppressions().Delete(channel, recipient)
+
+	if errors.Is(err, service.ErrSuppressionNotFound) {
+		return c.JSONPretty(http.StatusNotFound, map[string]string{
+			"status":  "not_found",
+			"message": err.Error(),
+		}, "")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSONPretty(http.StatusOK, map[string]string{
+		"status":  "deleted",
+		"message": "suppression is deleted",
+	}, "")
+}
+
+// ImportSuppressions add or replace many suppressions, valid items are written by one transaction
+func (x *MessengerController) ImportSuppressions() error {
+
+	c := x.webCtxt
+	dto := suppressionImportDTO{}
+	if err := c.Bind(&dto); err != nil {
+		return err
+	}
+
+	if len(dto.Items) == 0 {
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{
+			"status":  "empty_arg",
+			"message": "argument is empty: items",
+		}, "")
+	}
+
+	if len(dto.Items) > suppressionImportMaxSize {
+		return x.reject(&rejection{
+			HTTPStatus: http.StatusRequestEntityTooLarge,
+			Status:     "batch_too_large",
+			Message:    fmt.Sprintf("batch size %v exceeds %v", len(dto.Items), suppressionImportMaxSize),
+		})
+	}
+
+	res := suppressionImportedDTO{Items: []batchItemDTO{}}
+	items := make([]service.Suppression, 0, len(dto.Items))
+
+	for i, itm := range dto.Items {
+
+		if itm.Channel == "" {
+			itm.Channel = dto.Channel
+		}
+		if itm.Reason == "" {
+			itm.Reason = dto.Reason
+		}
+		if itm.Source == "" {
+			itm.Source = dto.Source
+		}
+		if itm.ExpiresAt == nil {
+			itm.ExpiresAt = dto.ExpiresAt
+		}
+
+		item, rejected, err := x.checkSuppression(itm, "import")
+		if err != nil {
+			return err
+		}
+
+		if rejected != nil {
+			res.Items = append(res.Items, batchItemDTO{Index: i, Status: rejected.Status, Message: rejected.Message})
+			continue
+		}
+
+		items = append(items, item)
+	}
+
+	if err := x.appService.Suppressions().Import(items); err != nil {
+		return err
+	}
+
+	res.Imported = len(items)
+	res.Rejected = len(res.Items)
+
+	return c.JSONPretty(http.StatusOK, res, "")
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-infra/internal/service"
+	xlog "go-infra/internal/util/utillog"
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HeaderIdempotencyKey request header with client key
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// HeaderIdempotentReplayed set on stored response
+const HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+// Idempotency repeated request with same Idempotency-Key returns stored 2xx response, handler is not called
+func Idempotency(store service.IdempotencyStore) echo.MiddlewareFunc {
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+
+			req := c.Request()
+
+			key := req.Header.Get(HeaderIdempotencyKey)
+			if key == "" {
+				return next(c)
+			}
+
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				return err
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+
+			// path with params, same key of other channel is other request
+			key = req.Method + " " + req.URL.Path + " " + key
+
+			if len(key) > service.IdempotencyKeyMaxLen {
+				return c.JSONPretty(http.StatusBadRequest, map[string]string{
+					"status":  "invalid_arg",
+					"message": fmt.Sprintf("%v is too long", HeaderIdempotencyKey),
+				}, "")
+			}
+
+			stored, err := store.Begin(key, requestFingerprint(req, body))
+
+			switch {
+			case errors.Is(err, service.ErrIdempotencyInProgress):
+				return c.JSONPretty(http.StatusConflict, map[string]string{
+					"status":  "conflict",
+					"message": err.Error(),
+				}, "")
+			case errors.Is(err, service.ErrIdempotencyMismatch):
+				return c.JSONPretty(http.StatusUnprocessableEntity, map[string]string{
+					"status":  "idempotency_key_reused",
+					"message": err.Error(),
+				}, "")
+			case err != nil:
+				return err
+			}
+
+			if stored != nil {
+				c.Response().Header().Set(HeaderIdempotentReplayed, "true")
+				return c.Blob(stored.Status, stored.ContentType, stored.Body)
+			}
+
+			completed := false
+
+			// not stored, request may be repeated, on panic too
+			defer func() {
+				if completed {
+					return
+				}
+				if errRelease := store.Release(key); errRelease != nil {
+					xlog.Error("idempotency release: %v", errRelease)
+				}
+			}()
+
+			res := c.Response()
+			capture := &captureWriter{ResponseWriter: res.Writer}
+			res.Writer = capture
+
+			err = next(c)
+
+			res.Writer = capture.ResponseWriter
+
+			if err == nil && res.Status >= 200 && res.Status < 300 {
+				completed = true
+				err = store.Complete(key, service.IdempotentResponse{
+					Status:      res.Status,
+					ContentType: res.Header().Get(echo.HeaderContentType),
+					Body:        capture.body.Bytes(),
+				})
+				if err != nil {
+					xlog.Error("idempotency complete: %v", err)
+				}
+				return nil
+			}
+
+			return err
+		}
+	}
+}
+
+// requestFingerprint same key with other request body is rejected
+func requestFingerprint(req *http.Request, body []byte) string {
+
+	h := sha256.New()
+	h.Write([]byte(req.URL.RawQuery + "\n"))
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// captureWriter copy of response body
+type captureWriter struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (x *captureWriter) Write(b []byte) (int, error) {
+	x.body.Write(b)
+	return x.ResponseWriter.Write(b)
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"go-infra/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+type releaseStore struct {
+	released []string
+}
+
+func (x *releaseStore) Begin(key string, fingerprint string) (*service.IdempotentResponse, error) {
+	return nil, nil
+}
+
+func (x *releaseStore) Complete(key string, res service.IdempotentResponse) error {
+	return nil
+}
+
+func (x *releaseStore) Release(key string) error {
+	x.released = append(x.released, key)
+	return nil
+}
+
+// Test too long key is rejected and key is released on panic of handler
+func TestIdempotency(t *testing.T) {
+
+	store := &releaseStore{}
+	e := echo.New()
+
+	handler := Idempotency(store)(func(c echo.Context) error {
+		panic("handler")
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/sms-text", nil)
+	req.Header.Set(HeaderIdempotencyKey, strings.Repeat("k", service.IdempotencyKeyMaxLen))
+	rec := httptest.NewRecorder()
+
+	if err := handler(e.NewContext(req, rec)); err != nil || rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 on long key, got %v %v", rec.Code, err)
+	}
+
+	req.Header.Set(HeaderIdempotencyKey, "k1")
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected panic")
+			}
+		}()
+		_ = handler(e.NewContext(req, httptest.NewRecorder()))
+	}()
+
+	if len(store.released) != 1 {
+		t.Errorf("Expected key released on panic, got %v", store.released)
+	}
+}
@@ -2,7 +2,14 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"go-infra/internal/service"
+	xlog "go-infra/internal/util/utillog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -13,18 +20,62 @@ func Init(e *echo.Echo, appService service.AppService) {
 	appConfig := appService.Config()
 
 	e.HTTPErrorHandler = newHTTPErrorHandler(appService)
+	e.IPExtractor = newIPExtractor(appConfig.HTTPServer.TrustedProxies) // per ip throttling
 
 	e.Use(middleware.Recover()) //!!!
 
 	if appConfig.HTTPServer.AccessLog {
-		e.Use(middleware.Logger())
+		e.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{
+			Output: xlog.RedactWriter(os.Stdout), // query of passcode endpoints
+		}))
 	}
 
 }
+
+// newIPExtractor client IP from X-Forwarded-For of trusted proxies only, peer address if none, panic on bad CIDR
+func newIPExtractor(trustedProxies string) echo.IPExtractor {
+
+	options := []echo.TrustOption{}
+
+	for _, itm := range strings.Split(trustedProxies, ",") {
+
+		itm = strings.TrimSpace(itm)
+		if itm == "" {
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(itm)
+		if err != nil {
+			panic(fmt.Errorf("error trusted proxy: %w", err))
+		}
+
+		options = append(options, echo.TrustIPRange(ipNet))
+	}
+
+	if len(options) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	// only configured proxies, not any private or loopback peer
+	options = append(options, echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false))
+
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
 func newHTTPErrorHandler(_ service.AppService) echo.HTTPErrorHandler {
 
 	return func(err error, c echo.Context) {
 
+		// recipient suppressed after request validation
+		var suppressedErr *service.SuppressedError
+		if errors.As(err, &suppressedErr) && !c.Response().Committed {
+			_ = c.JSONPretty(http.StatusUnprocessableEntity, map[string]string{
+				"status":  "suppressed",
+				"message": suppressedErr.Error(),
+			}, "")
+			return
+		}
+
 		c.Echo().DefaultHTTPErrorHandler(err, c)
 
 	}
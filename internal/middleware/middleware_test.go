@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNewIPExtractor(t *testing.T) {
+
+	req, _ := http.NewRequest(http.MethodPost, "/sys/api/messenger/otp/sms", nil)
+	req.RemoteAddr = "10.0.0.5:41000"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	req.Header.Set("X-Real-IP", "203.0.113.8")
+
+	// client headers are not trusted without configured proxies
+	if ip := newIPExtractor("")(req); ip != "10.0.0.5" {
+		t.Errorf("Expected peer address, got %v", ip)
+	}
+
+	if ip := newIPExtractor("10.0.0.0/24, 192.168.1.0/24")(req); ip != "203.0.113.7" {
+		t.Errorf("Expected forwarded address of trusted proxy, got %v", ip)
+	}
+
+	// private peer is not trusted unless configured
+	if ip := newIPExtractor("192.168.1.0/24")(req); ip != "10.0.0.5" {
+		t.Errorf("Expected peer address of untrusted proxy, got %v", ip)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic on bad CIDR")
+		}
+	}()
+	newIPExtractor("10.0.0.0/33")
+}
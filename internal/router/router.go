@@ -10,6 +10,7 @@ import (
 	"github.com/labstack/echo/v4"
 
 	controller "go-infra/internal/controller"
+	appmiddleware "go-infra/internal/middleware"
 
 	"go-infra/internal/config/consts"
 	"go-infra/internal/service"
@@ -155,10 +156,55 @@ func initMessengerController(e *echo.Echo, appService service.AppService) {
 
 	group := e.Group(consts.PathSysMessengerAPI)
 
-	group.POST("/sms-text", func(c echo.Context) error { return factory(c).SmsText() })
-	group.POST("/email-html", func(c echo.Context) error { return factory(c).EmailHTML() })
-	group.POST("/sms-passcode", func(c echo.Context) error { return factory(c).SmsPasscode() })
-	group.POST("/email-passcode", func(c echo.Context) error { return factory(c).EmailPasscode() })
+	idempotency := appmiddleware.Idempotency(appService.Idempotency())
+
+	group.POST("/sms-text", func(c echo.Context) error { return factory(c).SmsText() }, idempotency)
+	group.POST("/email-html", func(c echo.Context) error { return factory(c).EmailHTML() }, idempotency)
+	group.POST("/chat-text", func(c echo.Context) error { return factory(c).ChatText() }, idempotency)
+	group.POST("/sms-passcode", func(c echo.Context) error { return factory(c).SmsPasscode() }, idempotency)
+	group.POST("/email-passcode", func(c echo.Context) error { return factory(c).EmailPasscode() }, idempotency)
+	group.POST("/sms-template", func(c echo.Context) error { return factory(c).SmsTemplate() }, idempotency)
+	group.POST("/email-template", func(c echo.Context) error { return factory(c).EmailTemplate() }, idempotency)
+	group.POST("/sms-batch", func(c echo.Context) error { return factory(c).SmsBatch() }, idempotency)
+	group.POST("/email-batch", func(c echo.Context) error { return factory(c).EmailBatch() }, idempotency)
+
+	// channels declared in config by name, sent as channels of their type
+	channels := group.Group("/channels/:channel")
+
+	channels.POST("/sms-text", func(c echo.Context) error { return factory(c).SmsText() }, idempotency)
+	channels.POST("/email-html", func(c echo.Context) error { return factory(c).EmailHTML() }, idempotency)
+	channels.POST("/chat-text", func(c echo.Context) error { return factory(c).ChatText() }, idempotency)
+	channels.POST("/sms-passcode", func(c echo.Context) error { return factory(c).SmsPasscode() }, idempotency)
+	channels.POST("/email-passcode", func(c echo.Context) error { return factory(c).EmailPasscode() }, idempotency)
+	channels.POST("/sms-template", func(c echo.Context) error { return factory(c).SmsTemplate() }, idempotency)
+	channels.POST("/email-template", func(c echo.Context) error { return factory(c).EmailTemplate() }, idempotency)
+	channels.POST("/sms-batch", func(c echo.Context) error { return factory(c).SmsBatch() }, idempotency)
+	channels.POST("/email-batch", func(c echo.Context) error { return factory(c).EmailBatch() }, idempotency)
+
+	group.POST("/otp/verify", func(c echo.Context) error { return factory(c).OTPVerify() })
+	group.POST("/otp/:channel", func(c echo.Context) error { return factory(c).OTPIssue() }, idempotency)
+
+	group.GET("/messages/:id", func(c echo.Context) error { return factory(c).MessageStatus() })
+	group.POST("/messages/:id/cancel", func(c echo.Context) error { return factory(c).CancelMessage() })
+
+	group.GET("/spend", func(c echo.Context) error { return factory(c).Spend() })
+
+	group.GET("/sandbox", func(c echo.Context) error { return factory(c).SandboxMessages() })
+	group.GET("/sandbox/:id", func(c echo.Context) error { return factory(c).SandboxMessage() })
+	group.DELETE("/sandbox", func(c echo.Context) error { return factory(c).ClearSandbox() })
+
+	group.GET("/queues", func(c echo.Context) error { return factory(c).Queues() })
+
+	group.GET("/dead-letters/:channel", func(c echo.Context) error { return factory(c).DeadLetters() })
+	group.POST("/dead-letters/:channel/:id/requeue", func(c echo.Context) error { return factory(c).RequeueDeadLetter() })
+
+	group.GET("/suppressions", func(c echo.Context) error { return factory(c).Suppressions() })
+	group.POST("/suppressions", func(c echo.Context) error { return factory(c).AddSuppression() })
+	group.POST("/suppressions/import", func(c echo.Context) error { return factory(c).ImportSuppressions() })
+	group.GET("/suppressions/:channel/:recipient", func(c echo.Context) error { return factory(c).Suppression() })
+	group.DELETE("/suppressions/:channel/:recipient", func(c echo.Context) error { return factory(c).DeleteSuppression() })
+
+	group.POST("/webhooks/:channel/:gateway", func(c echo.Context) error { return factory(c).DeliveryReceipt() })
 
 	//
 
@@ -0,0 +1,666 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-infra/internal/config"
+	"go-infra/internal/repository"
+	xlog "go-infra/internal/util/utillog"
+	"go-infra/internal/util/utiltaskqueue"
+	"go-infra/internal/util/utiltasktimer"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Envelope common fields of channel messages, stored in outbox payload
+type Envelope struct {
+	ID        string
+	From      string
+	To        string
+	Lang      string
+	CreatedAt time.Time
+	MaxAge    int16                  // seconds, expires after createdAt+MaxAge if MaxAge>0
+	SendAt    time.Time              // scheduled delivery if in future, max age counts from it
+	Secrets   []string               `json:"-"` // masked in log output and outbox payload, passcode
+	Priority  utiltaskqueue.Priority // send queue lane, passcodes are high
+}
+
+// TaskID message id as task id
+func (x *Envelope) TaskID() string {
+	return x.ID
+}
+
+// TaskPriority send queue lane of message
+func (x *Envelope) TaskPriority() utiltaskqueue.Priority {
+	return x.Priority
+}
+
+func (x *Envelope) envelope() *Envelope {
+	return x
+}
+
+// channelMessage pointer to message of channel
+type channelMessage[T any] interface {
+	*T
+	envelope() *Envelope
+	prepare()        // before write to outbox
+	summary() string // debug output, secrets masked
+	Redacted() T
+}
+
+// ChannelDeadLetter message failed after all attempts, secrets are masked
+type ChannelDeadLetter struct {
+	ID       string    `json:"id"`
+	Data     any       `json:"data"`
+	Error    string    `json:"error"`
+	Attempts int       `json:"attempts"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// Channel message channel, queueing, retry, scheduling, metrics and gateway routing are shared by all channels
+type Channel interface {
+	Name() string
+	DeadLetters() []ChannelDeadLetter
+	Requeue(id string) error
+	Stats() utiltaskqueue.TaskQueueStats // send queue by priority lanes
+}
+
+// MessageChannel channel of typed messages
+type MessageChannel[T any] interface {
+	Channel
+	Send(message T) (string, error)           // message id
+	SendBatch(messages []T) ([]string, error) // message ids, all messages or none are enqueued
+}
+
+// SmsSender sms channel
+type SmsSender = MessageChannel[SmsMessage]
+
+// EmailSender email channel
+type EmailSender = MessageChannel[EmailMessage]
+
+// ChannelRegistry channels declared in config
+type ChannelRegistry interface {
+	Channel(name string) (Channel, bool)
+	Names() []string
+}
+
+// channelDeps services shared by channels
+type channelDeps struct {
+	repository   repository.AppRepository
+	suppressions SuppressionStore
+	spend        SpendLedger
+	sandbox      SandboxStore
+	owner        string // replica id, owner of pending outbox messages
+}
+
+// channelFactory new channel of type by channel config
+type channelFactory func(appConfig *config.AppConfig, cfg config.AppConfigChannel, deps channelDeps) (Channel, error)
+
+// channelTypes channel constructors by type
+var channelTypes = map[string]channelFactory{
+	ChannelSms:   newSmsChannel,
+	ChannelEmail: newEmailChannel,
+	ChannelChat:  newChatChannel,
+}
+
+type channelRegistry struct {
+	channels map[string]Channel
+	names    []string
+}
+
+// MustNewChannelRegistry build channels of config, panic on bad config
+func MustNewChannelRegistry(appConfig *config.AppConfig, repo repository.AppRepository,
+	suppressions SuppressionStore, spend SpendLedger, sandbox SandboxStore,
+) ChannelRegistry {
+
+	res := &channelRegistry{channels: map[string]Channel{}}
+	deps := channelDeps{
+		repository:   repo,
+		suppressions: suppressions,
+		spend:        spend,
+		sandbox:      sandbox,
+		owner:        newOutboxOwner(),
+	}
+
+	for _, cfg := range channelConfigs(appConfig) {
+
+		if _, ok := res.channels[cfg.Name]; ok {
+			panic(fmt.Errorf("error channel name is not unique: %v", cfg.Name))
+		}
+
+		factory, ok := channelTypes[cfg.Type]
+		if !ok {
+			panic(fmt.Errorf("error channel %v type not supported: %v", cfg.Name, cfg.Type))
+		}
+
+		channel, err := factory(appConfig, cfg, deps)
+		if err != nil {
+			panic(err)
+		}
+
+		res.channels[cfg.Name] = channel
+		res.names = append(res.names, cfg.Name)
+	}
+
+	return res
+}
+
+// Channel channel by name
+func (x *channelRegistry) Channel(name string) (Channel, bool) {
+	res, ok := x.channels[name]
+	return res, ok
+}
+
+// Names channel names in config order
+func (x *channelRegistry) Names() []string {
+	return slices.Clone(x.names)
+}
+
+// ChannelOf typed channel by name, false if missing or of other type
+func ChannelOf[T any](registry ChannelRegistry, name string) (MessageChannel[T], bool) {
+
+	channel, ok := registry.Channel(name)
+	if !ok {
+		return nil, false
+	}
+
+	res, ok := channel.(MessageChannel[T])
+
+	return res, ok
+}
+
+// mustMessageChannel typed channel by name, panic if missing or of other type
+func mustMessageChannel[T any](registry ChannelRegistry, name string) MessageChannel[T] {
+
+	channel, ok := registry.Channel(name)
+	if !ok {
+		panic(fmt.Errorf("error channel not exists: %v", name))
+	}
+
+	res, ok := channel.(MessageChannel[T])
+	if !ok {
+		panic(fmt.Errorf("error channel %v type is not %T", name, *new(T)))
+	}
+
+	return res
+}
+
+// channelConfigs declared channels, type is name if empty, sms, email and chat are added if missing,
+// channel without gateways uses sms_gateway(s) or email_gateway(s) of its type
+func channelConfigs(appConfig *config.AppConfig) []config.AppConfigChannel {
+
+	res := []config.AppConfigChannel{}
+
+	for _, itm := range appConfig.Channels {
+		if itm.Type == "" {
+			itm.Type = itm.Name
+		}
+		res = append(res, withTypeGateways(appConfig, itm))
+	}
+
+	for _, name := range []string{ChannelSms, ChannelEmail, ChannelChat} {
+		if !slices.ContainsFunc(res, func(v config.AppConfigChannel) bool { return v.Name == name }) {
+			res = append(res, withTypeGateways(appConfig, config.AppConfigChannel{Name: name, Type: name}))
+		}
+	}
+
+	return res
+}
+
+func withTypeGateways(appConfig *config.AppConfig, cfg config.AppConfigChannel) config.AppConfigChannel {
+
+	if len(cfg.Gateways) > 0 {
+		return cfg
+	}
+
+	var single config.AppConfigMessageGateway
+	var list []config.AppConfigMessageGateway
+	var routes []config.AppConfigGatewayRoute
+
+	switch cfg.Type {
+	case ChannelSms:
+		single, list, routes = appConfig.SmsGateway, appConfig.SmsGateways, appConfig.SmsRoutes
+	case ChannelEmail:
+		single, list, routes = appConfig.EmailGateway, appConfig.EmailGateways, appConfig.EmailRoutes
+	default:
+		return cfg
+	}
+
+	if len(list) == 0 {
+		list = []config.AppConfigMessageGateway{single}
+	}
+
+	cfg.Gateways = list
+	if len(cfg.Routes) == 0 {
+		cfg.Routes = routes
+	}
+
+	return cfg
+}
+
+// priorityLanes lane names of messenger priority config
+var priorityLanes = map[string]utiltaskqueue.Priority{
+	"high":   utiltaskqueue.PriorityHigh,
+	"normal": utiltaskqueue.PriorityNormal,
+	"low":    utiltaskqueue.PriorityLow,
+}
+
+// PriorityName lane name of priority
+func PriorityName(priority utiltaskqueue.Priority) string {
+	for name, v := range priorityLanes {
+		if v == priority {
+			return name
+		}
+	}
+	return strconv.Itoa(int(priority))
+}
+
+// withPriority set scheduling and lanes of send queue
+func withPriority[T any](queue *utiltaskqueue.TaskQueue[T], cfg config.AppConfigPriority) error {
+
+	switch cfg.Scheduling {
+	case "", utiltaskqueue.SchedulingStrict, utiltaskqueue.SchedulingWeighted:
+	default:
+		return fmt.Errorf("unknown priority scheduling %q", cfg.Scheduling)
+	}
+
+	lanes := map[utiltaskqueue.Priority]utiltaskqueue.LaneConfig{}
+
+	for name, lane := range cfg.Lanes {
+		priority, ok := priorityLanes[name]
+		if !ok {
+			return fmt.Errorf("unknown priority lane %q", name)
+		}
+		lanes[priority] = utiltaskqueue.LaneConfig{Weight: lane.Weight, MaxSize: lane.MaxSize}
+	}
+
+	queue.Scheduling = cfg.Scheduling
+	queue.Lanes = lanes
+
+	return nil
+}
+
+// messageChannel outbox, task queue with retry and dead letters, scheduler and gateway router of channel
+type messageChannel[T any, P channelMessage[T]] struct {
+	name         string
+	kind         string // channel type, suppressions are by type
+	debug        bool
+	outbox       outbox
+	router       *gatewayRouter
+	suppressions SuppressionStore
+	spend        SpendLedger
+	sandbox      SandboxStore
+	taskQueue    *utiltaskqueue.TaskQueue[T]
+	sendVia      func(gw *messageGateway, message P) (string, error) // provider message id returned
+}
+
+// newMessageChannel channel with pending messages resumed and scheduler started
+func newMessageChannel[T any, P channelMessage[T]](
+	appConfig *config.AppConfig,
+	cfg config.AppConfigChannel,
+	deps channelDeps,
+	sendVia func(gw *messageGateway, message P) (string, error),
+) (*messageChannel[T, P], error) {
+
+	router, err := newGatewayRouter(cfg.Name, config.AppConfigMessageGateway{}, cfg.Gateways, cfg.Routes)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, gw := range router.gateways {
+		if gw.config.Sandbox {
+			xlog.Warn("%v gateway %v is sandbox, messages are captured and not sent", cfg.Name, gw.name())
+		}
+	}
+
+	ob := outbox{channel: cfg.Name, repository: deps.repository, owner: deps.owner, lease: outboxLease(appConfig)}
+
+	res := &messageChannel[T, P]{
+		name:         cfg.Name,
+		kind:         cfg.Type,
+		debug:        appConfig.Debug,
+		outbox:       ob,
+		router:       router,
+		suppressions: deps.suppressions,
+		spend:        deps.spend,
+		sandbox:      deps.sandbox,
+		sendVia:      sendVia,
+	}
+
+	res.taskQueue = utiltaskqueue.NewTaskQueue(cfg.Name+" sender", res.handler, max(cfg.Workers, 1))
+	res.taskQueue.Retry = newRetryPolicy(appConfig.Messenger.Retry)
+	res.taskQueue.DeadLetters = outboxDeadLetters[T]{outbox: ob, limit: deadLettersLimit}
+
+	if err := withPriority(res.taskQueue, appConfig.Messenger.Priority); err != nil {
+		return nil, fmt.Errorf("channel %v: %w", cfg.Name, err)
+	}
+
+	if err := res.resume(); err != nil {
+		xlog.Error("%v sender resume: %v", cfg.Name, err)
+	}
+
+	scheduler := utiltasktimer.NewTaskTimer(cfg.Name+" scheduler", scheduleInterval(appConfig), res.release)
+	scheduler.Start()
+
+	return res, nil
+}
+
+// Name channel name
+func (x *messageChannel[T, P]) Name() string {
+	return x.name
+}
+
+// Send write message to outbox and enqueue, scheduled message is enqueued by send time, message id returned,
+// *SuppressedError if recipient is suppressed
+func (x *messageChannel[T, P]) Send(message T) (string, error) {
+
+	env := P(&message).envelope()
+
+	if err := x.suppressions.Check(x.kind, []string{env.To}); err != nil {
+		return "", err
+	}
+	env.ID = newMessageID()
+
+	P(&message).prepare()
+
+	row, err := x.row(&message)
+	if err != nil {
+		return "", err
+	}
+
+	if err := x.outbox.add(row); err != nil {
+		return "", err
+	}
+
+	metricMessages.WithLabelValues(x.name, row.Status).Inc()
+
+	if row.Status == OutboxStatusScheduled {
+		return env.ID, nil
+	}
+
+	if err := x.taskQueue.Enqueue(&message); err != nil {
+		_ = x.outbox.failed(env.ID, err.Error())
+		return "", err
+	}
+
+	return env.ID, nil
+}
+
+// SendBatch write messages to outbox by one transaction and enqueue, message ids returned,
+// *SuppressedError if any recipient is suppressed
+func (x *messageChannel[T, P]) SendBatch(messages []T) ([]string, error) {
+
+	recipients := make([]string, 0, len(messages))
+	for i := range messages {
+		recipients = append(recipients, P(&messages[i]).envelope().To)
+	}
+
+	if err := x.suppressions.Check(x.kind, recipients); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(messages))
+	rows := make([]OutboxMessage, 0, len(messages))
+	tasks := make([]*T, 0, len(messages))
+	taskIDs := make([]string, 0, len(messages))
+
+	for _, message := range messages {
+
+		env := P(&message).envelope()
+		env.ID = newMessageID()
+
+		P(&message).prepare()
+
+		row, err := x.row(&message)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, env.ID)
+		rows = append(rows, row)
+
+		if row.Status != OutboxStatusScheduled {
+			tasks = append(tasks, &message)
+			taskIDs = append(taskIDs, env.ID)
+		}
+	}
+
+	if err := x.outbox.addBatch(rows); err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		metricMessages.WithLabelValues(x.name, row.Status).Inc()
+	}
+
+	if len(tasks) == 0 {
+		return ids, nil
+	}
+
+	if err := x.taskQueue.EnqueueBatch(tasks); err != nil {
+		_ = x.outbox.failedBatch(taskIDs, err.Error())
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// row outbox row of message, payload of message with secrets is masked and the message is sent only
+// from memory queue of replica, so it can not be scheduled
+func (x *messageChannel[T, P]) row(message *T) (OutboxMessage, error) {
+
+	env := P(message).envelope()
+
+	if len(env.Secrets) == 0 {
+		return x.outbox.row(env.ID, message, env.SendAt)
+	}
+
+	if isScheduled(env.SendAt, time.Now()) {
+		return OutboxMessage{}, errors.New("message with secrets can not be scheduled")
+	}
+
+	res, err := x.outbox.row(env.ID, P(message).Redacted(), env.SendAt)
+	res.Masked = true
+
+	return res, err
+}
+
+// Stats send queue by priority lanes
+func (x *messageChannel[T, P]) Stats() utiltaskqueue.TaskQueueStats {
+	return x.taskQueue.Stats()
+}
+
+// DeadLetters messages failed after all attempts, secrets masked
+func (x *messageChannel[T, P]) DeadLetters() []ChannelDeadLetter {
+
+	list := x.taskQueue.DeadLetters.List()
+	res := make([]ChannelDeadLetter, 0, len(list))
+
+	for _, itm := range list {
+
+		var data any
+		if itm.Data != nil {
+			data = P(itm.Data).Redacted()
+		}
+
+		res = append(res, ChannelDeadLetter{
+			ID:       itm.ID,
+			Data:     data,
+			Error:    itm.Error,
+			Attempts: itm.Attempts,
+			FailedAt: itm.FailedAt,
+		})
+	}
+
+	return res
+}
+
+// Requeue send failed message again
+func (x *messageChannel[T, P]) Requeue(id string) error {
+	return x.taskQueue.Requeue(id)
+}
+
+// resume enqueue pending messages with lease expired, not finished before restart or left by stopped replica
+func (x *messageChannel[T, P]) resume() error {
+
+	for {
+		list, err := x.outbox.claim(time.Now(), outboxDueLimit)
+		if err != nil {
+			return err
+		}
+
+		if len(list) > 0 {
+			xlog.Info("%v sender resume pending messages: %v", x.name, len(list))
+		}
+
+		if err := x.enqueueRows(list); err != nil {
+			return err
+		}
+
+		if len(list) < outboxDueLimit {
+			return nil
+		}
+	}
+}
+
+// release renew lease of own pending messages, enqueue pending messages of stopped replicas
+// and scheduled messages with send time passed
+func (x *messageChannel[T, P]) release() error {
+
+	if err := x.outbox.renew(time.Now()); err != nil {
+		return err
+	}
+
+	if err := x.resume(); err != nil {
+		return err
+	}
+
+	list, err := x.outbox.due(time.Now(), outboxDueLimit)
+	if err != nil {
+		return err
+	}
+
+	if len(list) > 0 && x.debug {
+		xlog.Info("%v sender release scheduled messages: %v", x.name, len(list))
+	}
+
+	return x.enqueueRows(list)
+}
+
+// enqueueRows enqueue claimed outbox rows, rows with broken payload are failed,
+// rows not enqueued on queue error are unclaimed to be taken again
+func (x *messageChannel[T, P]) enqueueRows(list []OutboxMessage) error {
+
+	for i, itm := range list {
+
+		if itm.Masked {
+			_ = x.outbox.failed(itm.ID, errOutboxMasked.Error())
+			continue
+		}
+
+		message := new(T)
+		if err := json.Unmarshal([]byte(itm.Payload), message); err != nil {
+			_ = x.outbox.failed(itm.ID, err.Error())
+			continue
+		}
+		P(message).envelope().ID = itm.ID
+
+		if err := x.taskQueue.Enqueue(message); err != nil {
+			if errUnclaim := x.outbox.unclaim(list[i:]); errUnclaim != nil {
+				xlog.Error("%v outbox unclaim: %v", x.name, errUnclaim)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// handler task queue handler, expired message is dropped, gateways are tried by route
+func (x *messageChannel[T, P]) handler(message *T) error {
+
+	env := P(message).envelope()
+
+	if reason := expiredReason(maxAgeFrom(env.CreatedAt, env.SendAt), env.MaxAge, time.Now()); reason != "" {
+
+		metricMessagesExpired.WithLabelValues(x.name).Inc()
+		xlog.Warn("%v message %v dropped: %v", x.name, env.ID, reason)
+
+		return x.outbox.expired(env.ID, reason)
+	}
+
+	if err := x.outbox.sending(env.ID); err != nil {
+		return err
+	}
+
+	gateways, err := x.spend.available(x.name, x.router.route(env.To, env.Lang), env.To)
+
+	if err == nil {
+		_, err = x.router.send(gateways,
+			func(gw *messageGateway) (string, error) {
+
+				env.From = gw.config.From
+
+				if x.debug || gw.config.Stdout {
+					xlog.Info("gateway: `%v` to: `%v` %v", gw.name(), env.To, P(message).summary())
+				}
+
+				if gw.config.Sandbox {
+					return x.sandbox.capture(CapturedMessage{
+						MessageID: env.ID,
+						Channel:   x.name,
+						Gateway:   gw.name(),
+						To:        env.To,
+						Message:   *message,
+					}), nil
+				}
+
+				// cap is checked and price added at once, concurrent senders can not overshoot it
+				periods, err := x.spend.reserve(x.name, gw, env.To)
+				if err != nil {
+					return "", err
+				}
+
+				providerID, err := x.sendVia(gw, message)
+
+				if err == nil {
+					x.spend.commit(x.name, gw, env.To)
+				} else if errRefund := x.spend.refund(x.name, gw, env.To, periods); errRefund != nil {
+					xlog.Error("%v spend %v: %v", x.name, gw.name(), errRefund)
+				}
+
+				return providerID, err
+			},
+			func(gw *messageGateway, providerID string, err error) {
+				if errAttempt := x.outbox.attempt(env.ID, gw.name(), providerID, err); errAttempt != nil {
+					xlog.Error("%v outbox %v: %v", x.name, env.ID, errAttempt)
+				}
+			},
+		)
+	}
+
+	var errDone error
+	if err == nil {
+		errDone = x.outbox.sent(env.ID)
+	} else {
+		errDone = x.outbox.retry(env.ID, err) // dead letter store marks final fail
+	}
+
+	if errDone != nil {
+		xlog.Error("%v outbox %v: %v", x.name, env.ID, errDone)
+	}
+
+	metricMessages.WithLabelValues(x.name, sendStatus(err)).Inc()
+
+	return err
+}
+
+// sendStatus message status after send attempt
+func sendStatus(err error) string {
+	if err != nil {
+		return "retry"
+	}
+	return OutboxStatusSent
+}
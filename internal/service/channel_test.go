@@ -0,0 +1,145 @@
+package service
+
+import (
+	"encoding/json"
+	"go-infra/internal/config"
+	"go-infra/internal/util/utiltaskqueue"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test declared channels, default type and gateways of type
+func TestChannelConfigs(t *testing.T) {
+	appConfig := &config.AppConfig{
+		SmsGateway: config.AppConfigMessageGateway{From: "legacy"},
+		EmailRoutes: []config.AppConfigGatewayRoute{
+			{Gateway: "b", EmailDomain: []string{"example.com"}},
+		},
+		EmailGateways: []config.AppConfigMessageGateway{{Name: "a"}, {Name: "b"}},
+		Channels: []config.AppConfigChannel{
+			{Name: "sms-marketing", Type: ChannelSms, Workers: 4},
+			{Name: ChannelEmail, Gateways: []config.AppConfigMessageGateway{{Name: "c"}}},
+		},
+	}
+
+	list := channelConfigs(appConfig)
+
+	names := []string{}
+	for _, itm := range list {
+		names = append(names, itm.Name)
+	}
+	if len(names) != 4 || names[0] != "sms-marketing" || names[1] != ChannelEmail || names[2] != ChannelSms || names[3] != ChannelChat {
+		t.Fatalf("Unexpected channels: %v", names)
+	}
+
+	if gws := list[0].Gateways; len(gws) != 1 || gws[0].From != "legacy" || list[0].Workers != 4 {
+		t.Errorf("Expected sms gateway of type, got %+v", list[0])
+	}
+
+	if list[1].Type != ChannelEmail || len(list[1].Gateways) != 1 || list[1].Gateways[0].Name != "c" || len(list[1].Routes) != 0 {
+		t.Errorf("Expected declared gateways kept, got %+v", list[1])
+	}
+
+	if gws := list[2].Gateways; len(gws) != 1 || gws[0].From != "legacy" {
+		t.Errorf("Expected default sms channel, got %+v", list[2])
+	}
+
+	if list[3].Type != ChannelChat || len(list[3].Gateways) != 0 {
+		t.Errorf("Expected default chat channel without gateways, got %+v", list[3])
+	}
+
+	list = channelConfigs(&config.AppConfig{EmailGateways: appConfig.EmailGateways, EmailRoutes: appConfig.EmailRoutes})
+	if len(list[1].Gateways) != 2 || len(list[1].Routes) != 1 {
+		t.Errorf("Expected email gateways and routes, got %+v", list[1])
+	}
+}
+
+// Test typed channel lookup
+func TestMustMessageChannel(t *testing.T) {
+	registry := &channelRegistry{channels: map[string]Channel{
+		ChannelSms: &messageChannel[SmsMessage, *SmsMessage]{name: ChannelSms},
+	}}
+
+	if res := mustMessageChannel[SmsMessage](registry, ChannelSms); res.Name() != ChannelSms {
+		t.Errorf("Unexpected channel %v", res.Name())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic on channel of other type")
+		}
+	}()
+	mustMessageChannel[EmailMessage](registry, ChannelSms)
+}
+
+func TestWithPriority(t *testing.T) {
+	queue := utiltaskqueue.NewTaskQueue("test", func(*SmsMessage) error { return nil }, 1)
+
+	err := withPriority(queue, config.AppConfigPriority{
+		Scheduling: utiltaskqueue.SchedulingWeighted,
+		Lanes:      map[string]config.AppConfigPriorityLane{"high": {Weight: 8}, "low": {MaxSize: 100}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if queue.Scheduling != utiltaskqueue.SchedulingWeighted ||
+		queue.Lanes[utiltaskqueue.PriorityHigh].Weight != 8 || queue.Lanes[utiltaskqueue.PriorityLow].MaxSize != 100 {
+		t.Errorf("Unexpected queue lanes %v %+v", queue.Scheduling, queue.Lanes)
+	}
+
+	if err := withPriority(queue, config.AppConfigPriority{Scheduling: "fifo"}); err == nil {
+		t.Error("Expected error on unknown scheduling")
+	}
+	if err := withPriority(queue, config.AppConfigPriority{Lanes: map[string]config.AppConfigPriorityLane{"urgent": {}}}); err == nil {
+		t.Error("Expected error on unknown lane")
+	}
+}
+
+func TestEnvelopePriority(t *testing.T) {
+	payload, _ := json.Marshal(SmsMessage{Envelope: Envelope{To: "+10000000000", Priority: utiltaskqueue.PriorityHigh}})
+
+	message := &SmsMessage{}
+	if err := json.Unmarshal(payload, message); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// priority is kept in outbox payload, resumed messages return to their lane
+	var task any = message
+	if v, ok := task.(utiltaskqueue.TaskPriority); !ok || v.TaskPriority() != utiltaskqueue.PriorityHigh {
+		t.Errorf("Expected high priority of resumed message, got %+v", message.Envelope)
+	}
+
+	if PriorityName(utiltaskqueue.PriorityHigh) != "high" || PriorityName(utiltaskqueue.PriorityLow) != "low" {
+		t.Error("Unexpected priority names")
+	}
+}
+
+// Test passcode is masked in outbox payload, scheduled message with secrets is refused
+func TestMessageChannelRow_Secrets(t *testing.T) {
+	x := &messageChannel[SmsMessage, *SmsMessage]{outbox: outbox{channel: ChannelSms}}
+
+	message := &SmsMessage{Envelope: Envelope{ID: "1", To: "+10000000000", Secrets: []string{"987654"}}, Text: "Code: 987654"}
+
+	row, err := x.row(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !row.Masked || strings.Contains(row.Payload, "987654") {
+		t.Errorf("Expected masked payload, got %+v", row)
+	}
+	if message.Text != "Code: 987654" {
+		t.Errorf("Expected message kept for send, got %q", message.Text)
+	}
+
+	message.SendAt = time.Now().Add(time.Hour)
+	if _, err := x.row(message); err == nil {
+		t.Error("Expected error on scheduled message with secrets")
+	}
+
+	row, _ = x.row(&SmsMessage{Envelope: Envelope{ID: "2"}, Text: "hello"})
+	if row.Masked || !strings.Contains(row.Payload, "hello") {
+		t.Errorf("Expected plain payload, got %+v", row)
+	}
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-infra/internal/config"
+	"go-infra/internal/util/utilhttp"
+	"go-infra/internal/util/utilredact"
+	"go-infra/internal/util/utiltaskqueue"
+)
+
+// ChatMessage message to chat room by incoming webhook, To is room name
+type ChatMessage struct {
+	Envelope
+	Text        string
+	Blocks      json.RawMessage  // slack blocks as is
+	Attachments []ChatAttachment // slack and mattermost attachments
+}
+
+// ChatAttachment simple message attachment, same json in slack and mattermost
+type ChatAttachment struct {
+	Color     string      `json:"color,omitempty"` // good, warning, danger or #hex
+	Title     string      `json:"title,omitempty"`
+	TitleLink string      `json:"title_link,omitempty"`
+	Text      string      `json:"text,omitempty"`
+	Fields    []ChatField `json:"fields,omitempty"`
+	Footer    string      `json:"footer,omitempty"`
+}
+
+// ChatField attachment field, short fields are shown side by side
+type ChatField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short,omitempty"`
+}
+
+// Redacted copy with secrets masked
+func (message ChatMessage) Redacted() ChatMessage {
+	message.Text = utilredact.Secrets(message.Text, message.Secrets...)
+	message.Secrets = nil
+	return message
+}
+
+func (message *ChatMessage) prepare() {}
+
+func (message *ChatMessage) summary() string {
+	return fmt.Sprintf("message: `%v` attachments: %v", utilredact.Secrets(message.Text, message.Secrets...), len(message.Attachments))
+}
+
+// ChatSender chat channel
+type ChatSender = MessageChannel[ChatMessage]
+
+// chatPayload incoming webhook body, username and icon are ignored by slack app webhooks
+func chatPayload(room config.AppConfigChatRoom, message *ChatMessage) map[string]any {
+
+	res := map[string]any{"text": message.Text}
+
+	if room.Username != "" {
+		res["username"] = room.Username
+	}
+	if room.IconURL != "" {
+		res["icon_url"] = room.IconURL
+	}
+	if len(message.Blocks) > 0 {
+		res["blocks"] = message.Blocks
+	}
+	if len(message.Attachments) > 0 {
+		res["attachments"] = message.Attachments
+	}
+
+	return res
+}
+
+// newChatChannel channel of chat type, rooms are taken from messenger chat_rooms
+func newChatChannel(appConfig *config.AppConfig, cfg config.AppConfigChannel, deps channelDeps) (Channel, error) {
+
+	rooms := appConfig.Messenger.ChatRooms
+
+	sendChatVia := func(_ *messageGateway, message *ChatMessage) (string, error) {
+
+		room, ok := rooms[message.To]
+		if !ok || room.URL == "" {
+			return "", utiltaskqueue.Permanent(fmt.Errorf("chat room not configured: %v", message.To))
+		}
+
+		_, err := utilhttp.PostJSON(room.URL, nil, nil, chatPayload(room, message))
+
+		var statusErr *utilhttp.StatusError
+		if err != nil && !errors.As(err, &statusErr) {
+			err = errors.New(utilredact.Secrets(err.Error(), room.URL)) // webhook url is secret
+		}
+
+		return "", err // webhooks respond without message id
+	}
+
+	return newMessageChannel(appConfig, cfg, deps, sendChatVia)
+}
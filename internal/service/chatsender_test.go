@@ -0,0 +1,27 @@
+package service
+
+import (
+	"encoding/json"
+	"go-infra/internal/config"
+	"testing"
+)
+
+// Test incoming webhook body
+func TestChatPayload(t *testing.T) {
+	message := &ChatMessage{
+		Text:        "disk is full",
+		Attachments: []ChatAttachment{{Color: "danger", Fields: []ChatField{{Title: "host", Value: "db1", Short: true}}}},
+	}
+
+	data, _ := json.Marshal(chatPayload(config.AppConfigChatRoom{Username: "alerts"}, message))
+	expected := `{"attachments":[{"color":"danger","fields":[{"title":"host","value":"db1","short":true}]}],"text":"disk is full","username":"alerts"}`
+	if string(data) != expected {
+		t.Errorf("Unexpected payload %s", data)
+	}
+
+	message = &ChatMessage{Text: "x", Blocks: json.RawMessage(`[{"type":"divider"}]`)}
+	data, _ = json.Marshal(chatPayload(config.AppConfigChatRoom{}, message))
+	if string(data) != `{"blocks":[{"type":"divider"}],"text":"x"}` {
+		t.Errorf("Unexpected payload %s", data)
+	}
+}
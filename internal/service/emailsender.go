@@ -1,41 +1,52 @@
 package service
 
 import (
+	"encoding/json"
 	"fmt"
 	"go-infra/internal/config"
-	xlog "go-infra/internal/util/utillog"
+	"go-infra/internal/util/utilredact"
+	"go-infra/internal/util/utilsmtp"
 	"go-infra/internal/util/utiltaskqueue"
-	"time"
+	"strings"
 )
 
 type EmailMessage struct {
-	From      string
-	To        string
-	Lang      string
-	Subject   string
-	HTML      string
-	CreatedAt time.Time
-	MaxAge    int16 // expires after createdAt+MaxAge if MaxAge>0
+	Envelope
+	Cc          []string
+	Bcc         []string
+	ReplyTo     string
+	Subject     string
+	HTML        string
+	Text        string            // plain text alternative, generated from HTML if empty
+	Headers     map[string]string // custom headers
+	Attachments []EmailAttachment
 }
 
-type EmailSender interface {
-	Send(message EmailMessage) error
+// EmailAttachment file attached to email, inline image if ContentID is set
+type EmailAttachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type,omitempty"`
+	ContentID   string `json:"content_id,omitempty"`
+	Content     []byte `json:"content"` // base64 in json
 }
 
-type emailSender struct {
-	Debug     bool
-	taskQueue *utiltaskqueue.TaskQueue[EmailMessage]
+// Redacted copy with secrets masked
+func (message EmailMessage) Redacted() EmailMessage {
+	message.HTML = utilredact.Secrets(message.HTML, message.Secrets...)
+	message.Text = utilredact.Secrets(message.Text, message.Secrets...)
+	message.Secrets = nil
+	return message
 }
 
-func (x *emailSender) Send(message EmailMessage) error {
-
-	return x.taskQueue.Enqueue(&message)
-
+// prepare plain text alternative generated from HTML if empty
+func (message *EmailMessage) prepare() {
+	if message.Text == "" {
+		message.Text = utilsmtp.HTMLToText(message.HTML)
+	}
 }
 
-type emailTaskQueue struct {
-	Debug   bool
-	gateway config.AppConfigMessageGateway
+func (message *EmailMessage) summary() string {
+	return fmt.Sprintf("subject: `%v` message: `%v`", message.Subject, utilredact.Secrets(message.HTML, message.Secrets...))
 }
 
 func (message *EmailMessage) exctractValueForEmail(name string) (string, error) {
@@ -50,51 +61,112 @@ func (message *EmailMessage) exctractValueForEmail(name string) (string, error)
 		return message.Subject, nil
 	case "html":
 		return message.HTML, nil
+	case "text":
+		return message.Text, nil
+	case "cc":
+		return strings.Join(message.Cc, ","), nil
+	case "bcc":
+		return strings.Join(message.Bcc, ","), nil
+	case "reply_to":
+		return message.ReplyTo, nil
+	case "headers": // json object
+		return jsonString(message.Headers), nil
+	case "attachments": // json list of filename, content_type, content_id, content as base64
+		return jsonString(message.Attachments), nil
 	}
 
 	return "", fmt.Errorf("prop not exists: %s", name)
 }
-func (x emailTaskQueue) handlerEmail(emailMessage *EmailMessage) error {
 
-	gw := x.gateway
+// checkExtras error if message has attachments or headers and gateway request has no placeholder of them,
+// message is not sent without them
+func (message *EmailMessage) checkExtras(used map[string]bool) error {
+
+	if len(message.Attachments) > 0 && !used["attachments"] {
+		return fmt.Errorf("gateway request has no attachments placeholder, message has %v attachments", len(message.Attachments))
+	}
+
+	if len(message.Headers) > 0 && !used["headers"] {
+		return fmt.Errorf("gateway request has no headers placeholder, message has custom headers")
+	}
+
+	return nil
+}
+
+// templateValues message fields for gateway request template
+func (message *EmailMessage) templateValues() map[string]string {
+	return map[string]string{
+		"id":          message.ID,
+		"from":        message.From,
+		"to":          message.To,
+		"lang":        message.Lang,
+		"subject":     message.Subject,
+		"html":        message.HTML,
+		"text":        message.Text,
+		"cc":          strings.Join(message.Cc, ","),
+		"bcc":         strings.Join(message.Bcc, ","),
+		"reply_to":    message.ReplyTo,
+		"headers":     jsonString(message.Headers),
+		"attachments": jsonString(message.Attachments),
+	}
+}
+
+// jsonString json for gateway request template, empty object or list is `null`
+func jsonString(value any) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// sendEmailVia send via gateway, provider message id returned
+func sendEmailVia(gateway *messageGateway, emailMessage *EmailMessage) (string, error) {
 
-	emailMessage.From = gw.From
+	gw := gateway.config
 
-	if x.Debug || gw.Stdout {
-		xlog.Info("to: `%v` subject: `%v` message: `%v`", emailMessage.To, emailMessage.Subject, emailMessage.HTML)
+	if gw.SMTP {
+		return sendSMTP(gateway.smtpClient, emailMessage)
 	}
 
 	if gw.HTTP {
 
+		if gateway.request != nil {
+			if err := emailMessage.checkExtras(gateway.request.msgKeys); err != nil {
+				return "", utiltaskqueue.Permanent(err)
+			}
+			respBody, err := gateway.request.send(gw, emailMessage.templateValues())
+			return responseID(gw, respBody), err
+		}
+
 		sd := newDataSender()
 
-		err := sd.fillQuery(gw, emailMessage.exctractValueForEmail)
+		used := map[string]bool{}
+		extract := func(name string) (string, error) {
+			used[name] = true
+			return emailMessage.exctractValueForEmail(name)
+		}
+
+		err := sd.fillQuery(gw, extract)
 
 		if err != nil {
-			return err
+			return "", utiltaskqueue.Permanent(err)
 		}
-		err = sd.fillBody(gw, emailMessage.exctractValueForEmail)
+		err = sd.fillBody(gw, extract)
 
 		if err != nil {
-			return err
+			return "", utiltaskqueue.Permanent(err)
 		}
 
-		return sd.sendData(gw)
-
-	}
-	return nil
-}
-
-func NewEmailSender(appConfig *config.AppConfig) EmailSender {
+		if err := emailMessage.checkExtras(used); err != nil {
+			return "", utiltaskqueue.Permanent(err)
+		}
 
-	tq := emailTaskQueue{
+		respBody, err := sd.sendData(gw)
+		return responseID(gw, respBody), err
 
-		Debug:   appConfig.Debug,
-		gateway: appConfig.EmailGateway,
 	}
+	return "", nil
+}
 
-	return &emailSender{
-		Debug:     appConfig.Debug,
-		taskQueue: utiltaskqueue.NewTaskQueue("email sender", tq.handlerEmail, 1),
-	}
+// newEmailChannel channel of email type
+func newEmailChannel(appConfig *config.AppConfig, cfg config.AppConfigChannel, deps channelDeps) (Channel, error) {
+	return newMessageChannel(appConfig, cfg, deps, sendEmailVia)
 }
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"go-infra/internal/config"
+	"go-infra/internal/util/utilsmtp"
+	"go-infra/internal/util/utiltaskqueue"
+	"time"
+)
+
+func newSMTPClient(gw config.AppConfigMessageGateway) *utilsmtp.Client {
+
+	return utilsmtp.NewClient(utilsmtp.Config{
+		Host:        gw.SMTPHost,
+		Port:        gw.SMTPPort,
+		TLS:         gw.SMTPTLS,
+		Auth:        gw.SMTPAuth,
+		User:        gw.User,
+		Password:    gw.Password,
+		Timeout:     30 * time.Second,
+		IdleTimeout: 60 * time.Second,
+	})
+}
+
+// sendSMTP send email via smtp, 5xx replies are not retried, message id is provider id
+func sendSMTP(client *utilsmtp.Client, emailMessage *EmailMessage) (string, error) {
+
+	attachments := make([]utilsmtp.Attachment, 0, len(emailMessage.Attachments))
+	for _, itm := range emailMessage.Attachments {
+		attachments = append(attachments, utilsmtp.Attachment{
+			Filename:    itm.Filename,
+			ContentType: itm.ContentType,
+			ContentID:   itm.ContentID,
+			Data:        itm.Content,
+		})
+	}
+
+	err := client.Send(utilsmtp.Message{
+		ID:          emailMessage.ID,
+		From:        emailMessage.From,
+		To:          []string{emailMessage.To},
+		Cc:          emailMessage.Cc,
+		Bcc:         emailMessage.Bcc,
+		ReplyTo:     emailMessage.ReplyTo,
+		Subject:     emailMessage.Subject,
+		HTML:        emailMessage.HTML,
+		Text:        emailMessage.Text,
+		Headers:     emailMessage.Headers,
+		Attachments: attachments,
+	})
+
+	if err != nil && utilsmtp.IsPermanent(err) {
+		return "", utiltaskqueue.Permanent(err)
+	}
+
+	return emailMessage.ID, err
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-infra/internal/config"
+	"go-infra/internal/util/utilsmtp"
+	"go-infra/internal/util/utiltaskqueue"
+	"slices"
+	"strings"
+)
+
+// DefaultGatewayName name of single gateway from sms_gateway, email_gateway
+const DefaultGatewayName = "default"
+
+// messageGateway gateway config with prepared transport
+type messageGateway struct {
+	config     config.AppConfigMessageGateway
+	request    *gatewayRequest
+	smtpClient *utilsmtp.Client
+}
+
+func (x *messageGateway) name() string {
+	return x.config.Name
+}
+
+// gatewayRouter select gateways for message, first is primary, next ones for failover
+type gatewayRouter struct {
+	channel  string
+	gateways []*messageGateway
+	routes   []config.AppConfigGatewayRoute
+}
+
+// newGatewayRouter gateways list is used if not empty, otherwise single default gateway
+func newGatewayRouter(channel string,
+	single config.AppConfigMessageGateway,
+	list []config.AppConfigMessageGateway,
+	routes []config.AppConfigGatewayRoute,
+) (*gatewayRouter, error) {
+
+	if len(list) == 0 {
+		list = []config.AppConfigMessageGateway{single}
+	}
+
+	res := &gatewayRouter{
+		channel: channel,
+		routes:  routes,
+	}
+
+	for _, gw := range list {
+
+		if gw.Name == "" {
+			gw.Name = DefaultGatewayName
+		}
+
+		if slices.ContainsFunc(res.gateways, func(v *messageGateway) bool { return v.name() == gw.Name }) {
+			return nil, fmt.Errorf("%v gateway name is not unique: %v", channel, gw.Name)
+		}
+
+		request, err := newGatewayRequest(gw.Request)
+		if err != nil {
+			return nil, fmt.Errorf("%v gateway %v: %v", channel, gw.Name, err)
+		}
+
+		itm := &messageGateway{config: gw, request: request}
+
+		if gw.SMTP {
+			itm.smtpClient = newSMTPClient(gw)
+		}
+
+		res.gateways = append(res.gateways, itm)
+	}
+
+	for _, route := range routes {
+		if res.gateway(route.Gateway) == nil {
+			return nil, fmt.Errorf("%v route gateway not exists: %v", channel, route.Gateway)
+		}
+	}
+
+	return res, nil
+}
+
+func (x *gatewayRouter) gateway(name string) *messageGateway {
+	for _, gw := range x.gateways {
+		if gw.name() == name {
+			return gw
+		}
+	}
+	return nil
+}
+
+// route gateways of matched routes in routes order, then all other gateways in config order
+func (x *gatewayRouter) route(to string, lang string) []*messageGateway {
+
+	res := make([]*messageGateway, 0, len(x.gateways))
+
+	for _, route := range x.routes {
+		gw := x.gateway(route.Gateway)
+		if gw != nil && !slices.Contains(res, gw) && routeMatch(route, to, lang) {
+			res = append(res, gw)
+		}
+	}
+
+	for _, gw := range x.gateways {
+		if !slices.Contains(res, gw) {
+			res = append(res, gw)
+		}
+	}
+
+	return res
+}
+
+// send try gateways one by one until success, onAttempt called for every try with provider message id
+func (x *gatewayRouter) send(gateways []*messageGateway,
+	send func(gw *messageGateway) (string, error),
+	onAttempt func(gw *messageGateway, providerID string, err error),
+) (string, error) {
+
+	errs := []error{}
+	permanent := true
+
+	for _, gw := range gateways {
+
+		providerID, err := send(gw)
+
+		metricGatewaySends.WithLabelValues(x.channel, gw.name(), sendResult(err)).Inc()
+
+		if onAttempt != nil {
+			onAttempt(gw, providerID, err)
+		}
+
+		if err == nil {
+			return gw.name(), nil
+		}
+
+		errs = append(errs, fmt.Errorf("gateway %v: %v", gw.name(), err))
+		permanent = permanent && utiltaskqueue.IsPermanent(err)
+	}
+
+	if len(errs) == 0 {
+		return "", utiltaskqueue.Permanent(fmt.Errorf("%v gateway not exists", x.channel))
+	}
+
+	err := errors.New(errors.Join(errs...).Error()) // flat, keep retry decision below
+
+	if permanent {
+		return "", utiltaskqueue.Permanent(err)
+	}
+
+	return "", err
+}
+
+func sendResult(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// routeMatch all not empty conditions of route must match, empty route matches all
+func routeMatch(route config.AppConfigGatewayRoute, to string, lang string) bool {
+
+	if len(route.PhonePrefix) > 0 {
+		phone := normalizePhonePrefix(to)
+		if !slices.ContainsFunc(route.PhonePrefix, func(v string) bool {
+			return strings.HasPrefix(phone, normalizePhonePrefix(v))
+		}) {
+			return false
+		}
+	}
+
+	if len(route.EmailDomain) > 0 {
+		_, domain, _ := strings.Cut(strings.ToLower(strings.TrimSpace(to)), "@")
+		if !slices.ContainsFunc(route.EmailDomain, func(v string) bool {
+			v = strings.ToLower(v)
+			return domain == v || strings.HasSuffix(domain, "."+v)
+		}) {
+			return false
+		}
+	}
+
+	if len(route.Lang) > 0 && !slices.Contains(route.Lang, lang) {
+		return false
+	}
+
+	return true
+}
+
+// normalizePhonePrefix digits only, international 00 prefix dropped
+func normalizePhonePrefix(value string) string {
+
+	value = strings.TrimSpace(value)
+	isInternational := strings.HasPrefix(value, "+")
+
+	res := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, value)
+
+	if !isInternational {
+		res = strings.TrimPrefix(res, "00")
+	}
+
+	return res
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"errors"
+	"go-infra/internal/config"
+	"go-infra/internal/util/utiltaskqueue"
+	"strings"
+	"testing"
+)
+
+func gatewayNames(list []*messageGateway) []string {
+	res := []string{}
+	for _, gw := range list {
+		res = append(res, gw.name())
+	}
+	return res
+}
+
+// Test routing by phone prefix, email domain and lang
+func TestGatewayRouter_Route(t *testing.T) {
+	router, err := newGatewayRouter(ChannelSms, config.AppConfigMessageGateway{},
+		[]config.AppConfigMessageGateway{{Name: "global"}, {Name: "uk"}, {Name: "es"}, {Name: "corp"}},
+		[]config.AppConfigGatewayRoute{
+			{Gateway: "uk", PhonePrefix: []string{"+44"}},
+			{Gateway: "es", Lang: []string{"es"}},
+			{Gateway: "corp", EmailDomain: []string{"example.com"}},
+		},
+	)
+	if err != nil {
+		t.Fatalf("newGatewayRouter error: %v", err)
+	}
+
+	tests := []struct {
+		to       string
+		lang     string
+		expected string
+	}{
+		{to: "+123121234567", lang: "en", expected: "global,uk,es,corp"},
+		{to: "+44 7700 900123", lang: "en", expected: "uk,global,es,corp"},
+		{to: "0044 7700 900123", lang: "es", expected: "uk,es,global,corp"},
+		{to: "user@mail.example.com", lang: "en", expected: "corp,global,uk,es"},
+		{to: "user@example.org", lang: "es", expected: "es,global,uk,corp"},
+	}
+
+	for _, tt := range tests {
+		got := gatewayNames(router.route(tt.to, tt.lang))
+		if joined := strings.Join(got, ","); joined != tt.expected {
+			t.Errorf("route(%q, %q): expected %v, got %v", tt.to, tt.lang, tt.expected, joined)
+		}
+	}
+}
+
+// Test config errors and single default gateway
+func TestGatewayRouter_Config(t *testing.T) {
+	router, err := newGatewayRouter(ChannelSms, config.AppConfigMessageGateway{From: "App"}, nil, nil)
+	if err != nil {
+		t.Fatalf("newGatewayRouter error: %v", err)
+	}
+	if names := gatewayNames(router.gateways); len(names) != 1 || names[0] != DefaultGatewayName {
+		t.Errorf("Expected default gateway, got %v", names)
+	}
+
+	_, err = newGatewayRouter(ChannelSms, config.AppConfigMessageGateway{},
+		[]config.AppConfigMessageGateway{{Name: "a"}, {Name: "a"}}, nil)
+	if err == nil {
+		t.Error("Expected error for duplicated name")
+	}
+
+	_, err = newGatewayRouter(ChannelSms, config.AppConfigMessageGateway{},
+		[]config.AppConfigMessageGateway{{Name: "a"}}, []config.AppConfigGatewayRoute{{Gateway: "b"}})
+	if err == nil {
+		t.Error("Expected error for unknown route gateway")
+	}
+}
+
+// Test failover to next gateway and attempts record
+func TestGatewayRouter_Failover(t *testing.T) {
+	router, _ := newGatewayRouter(ChannelSms, config.AppConfigMessageGateway{},
+		[]config.AppConfigMessageGateway{{Name: "a"}, {Name: "b"}, {Name: "c"}}, nil)
+
+	attempts := []string{}
+	onAttempt := func(gw *messageGateway, _ string, err error) { attempts = append(attempts, gw.name()) }
+
+	name, err := router.send(router.gateways, func(gw *messageGateway) (string, error) {
+		if gw.name() == "a" {
+			return "", errors.New("gateway down")
+		}
+		return "", nil
+	}, onAttempt)
+
+	if err != nil || name != "b" {
+		t.Errorf("Expected send via b, got %v %v", name, err)
+	}
+	if strings.Join(attempts, ",") != "a,b" {
+		t.Errorf("Unexpected attempts: %v", attempts)
+	}
+
+	_, err = router.send(router.gateways, func(gw *messageGateway) (string, error) {
+		return "", utiltaskqueue.Permanent(errors.New("bad request"))
+	}, nil)
+	if !utiltaskqueue.IsPermanent(err) {
+		t.Errorf("Expected permanent error when all gateways reject, got %v", err)
+	}
+
+	_, err = router.send(router.gateways, func(gw *messageGateway) (string, error) {
+		if gw.name() == "c" {
+			return "", errors.New("timeout")
+		}
+		return "", utiltaskqueue.Permanent(errors.New("bad request"))
+	}, nil)
+	if err == nil || utiltaskqueue.IsPermanent(err) {
+		t.Errorf("Expected retryable error, got %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"go-infra/internal/config"
+	"go-infra/internal/util/utilhttp"
+	xlog "go-infra/internal/util/utillog"
+	"go-infra/internal/util/utiltaskqueue"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// gatewayRequest parsed request template of gateway
+type gatewayRequest struct {
+	method  string
+	url     *template.Template
+	headers map[string]*template.Template
+	body    *template.Template
+	consts  map[string]string
+	msgKeys map[string]bool // message fields referenced by templates
+}
+
+// gatewayRequestData template data
+type gatewayRequestData struct {
+	Msg      map[string]string
+	Const    map[string]string
+	From     string
+	User     string
+	Password string
+}
+
+var gatewayRequestFuncs = template.FuncMap{
+	"base64":     func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+	"urlencode":  url.QueryEscape,
+	"pathescape": url.PathEscape,
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	"basicauth": func(user, password string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+	},
+	"default": func(def string, v string) string {
+		if v == "" {
+			return def
+		}
+		return v
+	},
+}
+
+// newGatewayRequest parse templates, nil if request url is empty
+func newGatewayRequest(cfg config.AppConfigGatewayRequest) (*gatewayRequest, error) {
+
+	if cfg.URL == "" {
+		return nil, nil
+	}
+
+	res := &gatewayRequest{
+		method:  strings.ToUpper(cfg.Method),
+		headers: map[string]*template.Template{},
+		consts:  cfg.Consts,
+		msgKeys: map[string]bool{},
+	}
+
+	parse := func(name string, text string) (*template.Template, error) {
+		tmpl, err := template.New(name).Funcs(gatewayRequestFuncs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("gateway request %v: %v", name, err)
+		}
+		for _, t := range tmpl.Templates() {
+			if t.Tree != nil {
+				templateMsgKeys(t.Tree.Root, res.msgKeys)
+			}
+		}
+		return tmpl, nil
+	}
+
+	if res.method == "" {
+		res.method = http.MethodPost
+	}
+
+	var err error
+
+	if res.url, err = parse("url", cfg.URL); err != nil {
+		return nil, err
+	}
+
+	if cfg.Body != "" {
+		if res.body, err = parse("body", cfg.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	for key, value := range cfg.Headers {
+		if res.headers[key], err = parse("header "+key, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// templateMsgKeys collect message fields of template by .Msg.name, $.Msg.name and index .Msg "name"
+func templateMsgKeys(node parse.Node, res map[string]bool) {
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, itm := range n.Nodes {
+			templateMsgKeys(itm, res)
+		}
+	case *parse.ActionNode:
+		templateMsgKeys(n.Pipe, res)
+	case *parse.IfNode:
+		templateMsgKeys(&n.BranchNode, res)
+	case *parse.RangeNode:
+		templateMsgKeys(&n.BranchNode, res)
+	case *parse.WithNode:
+		templateMsgKeys(&n.BranchNode, res)
+	case *parse.BranchNode:
+		templateMsgKeys(n.Pipe, res)
+		templateMsgKeys(n.List, res)
+		templateMsgKeys(n.ElseList, res)
+	case *parse.TemplateNode:
+		templateMsgKeys(n.Pipe, res)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			templateMsgKeys(cmd, res)
+		}
+	case *parse.CommandNode:
+		if len(n.Args) >= 3 {
+			fn, isIdent := n.Args[0].(*parse.IdentifierNode)
+			msg, isField := n.Args[1].(*parse.FieldNode)
+			key, isString := n.Args[2].(*parse.StringNode)
+			if isIdent && fn.Ident == "index" && isField && slices.Equal(msg.Ident, []string{"Msg"}) && isString {
+				res[key.Text] = true
+			}
+		}
+		for _, arg := range n.Args {
+			templateMsgKeys(arg, res)
+		}
+	case *parse.FieldNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "Msg" {
+			res[n.Ident[1]] = true
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 2 && n.Ident[0] == "$" && n.Ident[1] == "Msg" {
+			res[n.Ident[2]] = true
+		}
+	}
+}
+
+// render build request url, headers and body
+func (x *gatewayRequest) render(gw config.AppConfigMessageGateway, values map[string]string) (string, map[string]string, []byte, error) {
+
+	data := gatewayRequestData{
+		Msg:      values,
+		Const:    x.consts,
+		From:     gw.From,
+		User:     gw.User,
+		Password: gw.Password,
+	}
+
+	if data.Const == nil {
+		data.Const = map[string]string{}
+	}
+
+	exec := func(tmpl *template.Template) ([]byte, error) {
+		bu := bytes.Buffer{}
+		if err := tmpl.Execute(&bu, data); err != nil {
+			return nil, err
+		}
+		return bu.Bytes(), nil
+	}
+
+	reqURL, err := exec(x.url)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	headers := map[string]string{}
+	for key, tmpl := range x.headers {
+		value, err := exec(tmpl)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		headers[key] = string(value)
+	}
+
+	var body []byte
+	if x.body != nil {
+		if body, err = exec(x.body); err != nil {
+			return "", nil, nil, err
+		}
+	}
+
+	return strings.TrimSpace(string(reqURL)), headers, body, nil
+}
+
+// send render and send request, response body returned
+func (x *gatewayRequest) send(gw config.AppConfigMessageGateway, values map[string]string) ([]byte, error) {
+
+	reqURL, headers, body, err := x.render(gw, values)
+	if err != nil {
+		return nil, utiltaskqueue.Permanent(fmt.Errorf("gateway request: %v", err))
+	}
+
+	respBody, err := utilhttp.Send(x.method, reqURL, headers, body)
+
+	if gw.Stdout {
+		if err != nil && len(respBody) > 0 {
+			xlog.Info("resp: %s", string(respBody))
+		}
+	}
+
+	return respBody, err
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-infra/internal/config"
+	"go-infra/internal/repository"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// idempotency stores
+const (
+	IdempotencyStoreMemory = "memory"
+	IdempotencyStoreDB     = "db"
+)
+
+// IdempotencyKeyMaxLen max length of stored key
+const IdempotencyKeyMaxLen = 255
+
+var (
+	// ErrIdempotencyInProgress request with same key is not finished
+	ErrIdempotencyInProgress = errors.New("request with same idempotency key is in progress")
+	// ErrIdempotencyMismatch key is used with other request
+	ErrIdempotencyMismatch = errors.New("idempotency key is used with other request")
+)
+
+// IdempotentResponse stored response of request
+type IdempotentResponse struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// IdempotencyStore responses by idempotency key
+type IdempotencyStore interface {
+	// Begin reserve key for lease, stored response returned if key is completed in window
+	Begin(key string, fingerprint string) (*IdempotentResponse, error)
+	// Complete store response of reserved key for window
+	Complete(key string, res IdempotentResponse) error
+	// Release drop reserved key, request may be repeated
+	Release(key string) error
+}
+
+// NewIdempotencyStore store by config, memory for single replica, db shared by replicas
+func NewIdempotencyStore(appConfig *config.AppConfig, repo repository.AppRepository) IdempotencyStore {
+
+	cfg := appConfig.Messenger
+	window := time.Duration(cfg.IdempotencyWindow) * time.Second
+	lease := idempotencyLease(cfg.IdempotencyLease, window)
+
+	switch cfg.IdempotencyStore {
+	case IdempotencyStoreMemory, "":
+		return newMemoryIdempotencyStore(window, lease)
+	case IdempotencyStoreDB:
+		return &dbIdempotencyStore{window: window, lease: lease, repository: repo}
+	}
+
+	panic(fmt.Errorf("error idempotency store not supported: %v", cfg.IdempotencyStore))
+}
+
+// idempotencyLease reservation of key in progress, key of crashed request is free after it, not longer than window
+func idempotencyLease(seconds int, window time.Duration) time.Duration {
+
+	res := time.Duration(seconds) * time.Second
+	if res <= 0 {
+		res = time.Minute
+	}
+
+	return min(res, window)
+}
+
+type idempotencyEntry struct {
+	fingerprint string
+	done        bool
+	response    IdempotentResponse
+	expiresAt   time.Time
+}
+
+// memoryIdempotencyStore single replica store
+type memoryIdempotencyStore struct {
+	window    time.Duration
+	lease     time.Duration
+	mu        sync.Mutex
+	entries   map[string]*idempotencyEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func newMemoryIdempotencyStore(window time.Duration, lease time.Duration) *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{
+		window:  window,
+		lease:   lease,
+		entries: map[string]*idempotencyEntry{},
+		now:     time.Now,
+	}
+}
+
+// Begin reserve key
+func (x *memoryIdempotencyStore) Begin(key string, fingerprint string) (*IdempotentResponse, error) {
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	now := x.now()
+	x.sweep(now)
+
+	if itm, ok := x.entries[key]; ok && now.Before(itm.expiresAt) {
+		return itm.result(fingerprint)
+	}
+
+	x.entries[key] = &idempotencyEntry{fingerprint: fingerprint, expiresAt: now.Add(x.lease)}
+
+	return nil, nil
+}
+
+// Complete store response
+func (x *memoryIdempotencyStore) Complete(key string, res IdempotentResponse) error {
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if itm, ok := x.entries[key]; ok {
+		itm.done = true
+		itm.response = res
+		itm.expiresAt = x.now().Add(x.window)
+	}
+
+	return nil
+}
+
+// Release drop key
+func (x *memoryIdempotencyStore) Release(key string) error {
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	delete(x.entries, key)
+
+	return nil
+}
+
+// sweep drop expired keys, not often than once a minute
+func (x *memoryIdempotencyStore) sweep(now time.Time) {
+
+	if now.Sub(x.lastSweep) < time.Minute {
+		return
+	}
+
+	x.lastSweep = now
+
+	for k, v := range x.entries {
+		if !now.Before(v.expiresAt) {
+			delete(x.entries, k)
+		}
+	}
+}
+
+func (x *idempotencyEntry) result(fingerprint string) (*IdempotentResponse, error) {
+
+	if x.fingerprint != fingerprint {
+		return nil, ErrIdempotencyMismatch
+	}
+
+	if !x.done {
+		return nil, ErrIdempotencyInProgress
+	}
+
+	res := x.response
+
+	return &res, nil
+}
+
+// IdempotencyKey stored response by idempotency key
+type IdempotencyKey struct {
+	Key         string `gorm:"primaryKey;size:255"`
+	Fingerprint string `gorm:"size:64"`
+	Done        bool
+	Status      int
+	ContentType string `gorm:"size:128"`
+	Body        []byte
+	ExpiresAt   time.Time `gorm:"index"`
+	CreatedAt   time.Time
+}
+
+// dbIdempotencyStore store shared by replicas
+type dbIdempotencyStore struct {
+	window     time.Duration
+	lease      time.Duration
+	repository repository.AppRepository
+}
+
+// Begin reserve key, insert is atomic by primary key
+func (x *dbIdempotencyStore) Begin(key string, fingerprint string) (*IdempotentResponse, error) {
+
+	now := time.Now()
+
+	// drop expired keys, this one included
+	err := x.repository.Where("expires_at <= ?", now).Delete(&IdempotencyKey{}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	tx := x.repository.Driver().Clauses(clause.OnConflict{DoNothing: true}).Create(&IdempotencyKey{
+		Key:         key,
+		Fingerprint: fingerprint,
+		ExpiresAt:   now.Add(x.lease),
+	})
+
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	if tx.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	row := IdempotencyKey{}
+
+	err = x.repository.Where("key = ?", key).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrIdempotencyInProgress // released meanwhile
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	itm := &idempotencyEntry{
+		fingerprint: row.Fingerprint,
+		done:        row.Done,
+		response: IdempotentResponse{
+			Status:      row.Status,
+			ContentType: row.ContentType,
+			Body:        row.Body,
+		},
+	}
+
+	return itm.result(fingerprint)
+}
+
+// Complete store response
+func (x *dbIdempotencyStore) Complete(key string, res IdempotentResponse) error {
+
+	return x.repository.Model(&IdempotencyKey{}).Where("key = ?", key).Updates(map[string]any{
+		"done":         true,
+		"status":       res.Status,
+		"content_type": res.ContentType,
+		"body":         res.Body,
+		"expires_at":   time.Now().Add(x.window),
+	}).Error
+}
+
+// Release drop key
+func (x *dbIdempotencyStore) Release(key string) error {
+	return x.repository.Where("key = ?", key).Delete(&IdempotencyKey{}).Error
+}
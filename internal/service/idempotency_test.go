@@ -0,0 +1,60 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Test memory store reserve, replay, mismatch and expiry
+func TestMemoryIdempotencyStore(t *testing.T) {
+	now := time.Now()
+	store := newMemoryIdempotencyStore(time.Minute, time.Second)
+	store.now = func() time.Time { return now }
+
+	res, err := store.Begin("k1", "f1")
+	if res != nil || err != nil {
+		t.Fatalf("Expected key reserved, got %v %v", res, err)
+	}
+
+	if _, err := store.Begin("k1", "f1"); !errors.Is(err, ErrIdempotencyInProgress) {
+		t.Errorf("Expected in progress, got %v", err)
+	}
+
+	_ = store.Complete("k1", IdempotentResponse{Status: 200, ContentType: "application/json", Body: []byte(`{"id":"1"}`)})
+
+	res, err = store.Begin("k1", "f1")
+	if err != nil || res == nil || res.Status != 200 || string(res.Body) != `{"id":"1"}` {
+		t.Errorf("Expected stored response, got %+v %v", res, err)
+	}
+
+	if _, err := store.Begin("k1", "f2"); !errors.Is(err, ErrIdempotencyMismatch) {
+		t.Errorf("Expected mismatch, got %v", err)
+	}
+
+	// released key may be used again
+	_, _ = store.Begin("k2", "f1")
+	_ = store.Release("k2")
+	if res, err := store.Begin("k2", "f1"); res != nil || err != nil {
+		t.Errorf("Expected released key reserved again, got %v %v", res, err)
+	}
+
+	// reservation of crashed request is free after lease, completed key is kept for window
+	_, _ = store.Begin("k3", "f1")
+	now = now.Add(2 * time.Second)
+	if res, err := store.Begin("k3", "f1"); res != nil || err != nil {
+		t.Errorf("Expected key reserved again after lease, got %v %v", res, err)
+	}
+	if res, err := store.Begin("k1", "f1"); err != nil || res == nil {
+		t.Errorf("Expected stored response after lease, got %+v %v", res, err)
+	}
+
+	now = now.Add(2 * time.Minute)
+
+	if res, err := store.Begin("k1", "f2"); res != nil || err != nil {
+		t.Errorf("Expected expired key reserved again, got %v %v", res, err)
+	}
+	if len(store.entries) != 1 {
+		t.Errorf("Expected expired keys swept, got %d", len(store.entries))
+	}
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"errors"
+	"go-infra/internal/config"
+	"go-infra/internal/repository"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrMessageNotFound message id not exists
+var ErrMessageNotFound = errors.New("message not found")
+
+// ErrMessageNotScheduled message is released to queue or not scheduled
+var ErrMessageNotScheduled = errors.New("message is not scheduled")
+
+// MessageAttempt send attempt via gateway
+type MessageAttempt struct {
+	Gateway   string    `json:"gateway"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MessageStatus message lifecycle state
+type MessageStatus struct {
+	ID       string `json:"id"`
+	Channel  string `json:"channel"`
+	Status   string `json:"status"` // scheduled, canceled, queued, sending, sent, failed, expired, delivered, undelivered, bounced
+	Attempts int    `json:"attempts"`
+	Gateway  string `json:"gateway,omitempty"`
+	// provider message id and status from delivery receipt
+	ProviderID     string           `json:"provider_id,omitempty"`
+	ProviderStatus string           `json:"provider_status,omitempty"`
+	LastError      string           `json:"last_error,omitempty"`
+	SendAt         *time.Time       `json:"send_at,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+	History        []MessageAttempt `json:"history"`
+}
+
+// MessageStore messages accepted by senders
+type MessageStore interface {
+	Status(id string) (*MessageStatus, error)
+	// Cancel scheduled message before it is released to queue
+	Cancel(id string) error
+	// ApplyReceipts update messages by delivery receipts of gateway, data is decoded json or form values
+	ApplyReceipts(channel string, gateway string, token string, data any) (ReceiptResult, error)
+}
+
+type messageStore struct {
+	appConfig  *config.AppConfig
+	repository repository.AppRepository
+}
+
+// NewMessageStore new store over outbox
+func NewMessageStore(appConfig *config.AppConfig, repo repository.AppRepository) MessageStore {
+	return &messageStore{appConfig: appConfig, repository: repo}
+}
+
+// Status message status with attempts history
+func (x *messageStore) Status(id string) (*MessageStatus, error) {
+
+	row := OutboxMessage{}
+
+	err := x.repository.Where("id = ?", id).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	attempts := []OutboxAttempt{}
+
+	err = x.repository.Where("message_id = ?", id).Order("id").Find(&attempts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	res := &MessageStatus{
+		ID:             row.ID,
+		Channel:        row.Channel,
+		Status:         row.Status,
+		Attempts:       row.Attempts,
+		Gateway:        row.Gateway,
+		ProviderID:     row.ProviderID,
+		ProviderStatus: row.ProviderStatus,
+		LastError:      row.LastError,
+		SendAt:         row.SendAt,
+		CreatedAt:      row.CreatedAt,
+		UpdatedAt:      row.UpdatedAt,
+		History:        make([]MessageAttempt, 0, len(attempts)),
+	}
+
+	for _, itm := range attempts {
+		res.History = append(res.History, MessageAttempt{
+			Gateway:   itm.Gateway,
+			Error:     itm.Error,
+			CreatedAt: itm.CreatedAt,
+		})
+	}
+
+	return res, nil
+}
+
+// Cancel scheduled message, ErrMessageNotScheduled if it is released already
+func (x *messageStore) Cancel(id string) error {
+
+	res := x.repository.Model(&OutboxMessage{}).
+		Where("id = ? and status = ?", id, OutboxStatusScheduled).
+		Update("status", OutboxStatusCanceled)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected > 0 {
+		return nil
+	}
+
+	row := OutboxMessage{}
+
+	err := x.repository.Select("id").Where("id = ?", id).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrMessageNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return ErrMessageNotScheduled
+}
+
+// ApplyReceipts update messages by delivery receipts of gateway
+func (x *messageStore) ApplyReceipts(channel string, gateway string, token string, data any) (ReceiptResult, error) {
+
+	res := ReceiptResult{}
+
+	cfg, ok := receiptConfig(x.appConfig, channel, gateway)
+	if !ok || !receiptTokenValid(cfg, token) {
+		return res, ErrReceiptForbidden
+	}
+
+	box := outbox{channel: channel, repository: x.repository}
+
+	for _, receipt := range parseReceipts(cfg, data) {
+
+		found, err := box.receipt(gateway, receipt)
+		if err != nil {
+			return res, err
+		}
+
+		if found {
+			res.Updated++
+		} else {
+			res.Unmatched++
+		}
+
+		metricReceipts.WithLabelValues(channel, gateway, receiptMetricStatus(found, receipt)).Inc()
+	}
+
+	return res, nil
+}
+
+func receiptMetricStatus(found bool, receipt gatewayReceipt) string {
+	if !found {
+		return "unmatched"
+	}
+	if receipt.Status == "" {
+		return "unknown"
+	}
+	return receipt.Status
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	metricMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "messenger_messages_total",
+		Help: "Messages per channel and status, accepted and after send attempt",
+	}, []string{"channel", "status"})
+
+	metricMessagesExpired = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "messenger_messages_expired_total",
+		Help: "Messages dropped before send because of max age",
+	}, []string{"channel"})
+
+	metricGatewaySends = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "messenger_gateway_sends_total",
+		Help: "Send attempts per gateway and result",
+	}, []string{"channel", "gateway", "result"})
+
+	metricGatewaySpend = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "messenger_gateway_spend_total",
+		Help: "Cost of messages sent per gateway",
+	}, []string{"channel", "gateway"})
+
+	metricGatewayCapped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "messenger_gateway_capped_total",
+		Help: "Messages not sent via gateway because of spend cap",
+	}, []string{"channel", "gateway"})
+
+	metricReceipts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "messenger_receipts_total",
+		Help: "Delivery receipts per gateway and status",
+	}, []string{"channel", "gateway", "status"})
+
+	metricThrottle = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "messenger_throttle_total",
+		Help: "Passcode requests per throttle rule and result",
+	}, []string{"channel", "rule", "result"})
+)
@@ -3,7 +3,35 @@ package service
 
 func mustCreateRepository(appService AppService) {
 
-	_ = appService.Repository()
+	repo := appService.Repository()
+
+	if err := repo.AutoMigrate(&OutboxMessage{}); err != nil {
+		panic(err)
+	}
+
+	if err := repo.AutoMigrate(&OutboxAttempt{}); err != nil {
+		panic(err)
+	}
+
+	if err := repo.AutoMigrate(&IdempotencyKey{}); err != nil {
+		panic(err)
+	}
+
+	if err := repo.AutoMigrate(&OtpCode{}); err != nil {
+		panic(err)
+	}
+
+	if err := repo.AutoMigrate(&ThrottleHit{}); err != nil {
+		panic(err)
+	}
+
+	if err := repo.AutoMigrate(&Suppression{}); err != nil {
+		panic(err)
+	}
+
+	if err := repo.AutoMigrate(&GatewaySpend{}); err != nil {
+		panic(err)
+	}
 
 	mustInitRepositoryMasterData(appService)
 }
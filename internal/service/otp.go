@@ -0,0 +1,227 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-infra/internal/config"
+	"go-infra/internal/repository"
+	xlog "go-infra/internal/util/utillog"
+	"math/big"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrOTPNotFound code not exists
+	ErrOTPNotFound = errors.New("passcode not found")
+	// ErrOTPExpired code ttl passed
+	ErrOTPExpired = errors.New("passcode expired")
+	// ErrOTPUsed code is verified already or replaced by new one
+	ErrOTPUsed = errors.New("passcode already used")
+	// ErrOTPAttemptsExceeded max verify attempts reached
+	ErrOTPAttemptsExceeded = errors.New("passcode verify attempts exceeded")
+	// ErrOTPInvalid code not matched
+	ErrOTPInvalid = errors.New("passcode invalid")
+)
+
+// OtpCode hashed one time passcode
+type OtpCode struct {
+	ID          string `gorm:"primaryKey;size:32"`
+	Channel     string `gorm:"size:16;index:idx_otp_channel_to"`
+	To          string `gorm:"size:255;index:idx_otp_channel_to"`
+	Salt        string `gorm:"size:32"`
+	Hash        string `gorm:"size:64"`
+	Attempts    int
+	MaxAttempts int
+	ExpiresAt   time.Time
+	UsedAt      *time.Time
+	CreatedAt   time.Time
+}
+
+// OTPChallenge issued code, code is sent to user and not stored
+type OTPChallenge struct {
+	ID        string
+	ExpiresAt time.Time
+}
+
+// OTPVerify verify request, by challenge id or latest code of channel and recipient
+type OTPVerify struct {
+	ID      string
+	Channel string
+	To      string
+	Code    string
+}
+
+// OTPService one time passcodes
+type OTPService interface {
+	// Issue new code sent by send, previous codes of recipient are invalidated only if send succeeds
+	Issue(channel string, to string, send func(code string) error) (*OTPChallenge, error)
+	// Verify check code, code is invalidated on success or when attempts are exceeded
+	Verify(req OTPVerify) error
+}
+
+type otpService struct {
+	config     config.AppConfigOTP
+	repository repository.AppRepository
+}
+
+// NewOTPService new service, panic on bad config
+func NewOTPService(appConfig *config.AppConfig, repo repository.AppRepository) OTPService {
+
+	cfg := appConfig.Messenger.OTP
+
+	if cfg.Length <= 0 || utf8.RuneCountInString(cfg.Alphabet) < 2 || cfg.TTL <= 0 || cfg.MaxAttempts <= 0 {
+		panic(fmt.Errorf("error otp config: length, ttl, max attempts must be positive, alphabet at least 2 chars"))
+	}
+
+	if cfg.Pepper == "" {
+		xlog.Warn("otp pepper is empty, hashes of short codes can be brute forced from database")
+	}
+
+	return &otpService{config: cfg, repository: repo}
+}
+
+// Issue new code, code row, send and invalidation of previous codes are one transaction,
+// previous codes stay valid if send fails
+func (x *otpService) Issue(channel string, to string, send func(code string) error) (*OTPChallenge, error) {
+
+	code, err := generateOTP(x.config.Length, x.config.Alphabet)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := newMessageID()
+	now := time.Now()
+
+	row := &OtpCode{
+		ID:          newMessageID(),
+		Channel:     channel,
+		To:          to,
+		Salt:        salt,
+		Hash:        otpHash(x.config.Pepper, salt, code),
+		MaxAttempts: x.config.MaxAttempts,
+		ExpiresAt:   now.Add(time.Duration(x.config.TTL) * time.Second),
+	}
+
+	err = x.repository.Transaction(func(tx repository.AppRepository) error {
+
+		if err := tx.Create(row).Error; err != nil {
+			return err
+		}
+
+		if err := send(code); err != nil {
+			return err
+		}
+
+		return tx.Model(&OtpCode{}).Where("channel = ? and \"to\" = ? and used_at is null and id <> ?", channel, to, row.ID).
+			Update("used_at", now).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &OTPChallenge{ID: row.ID, ExpiresAt: row.ExpiresAt}, nil
+}
+
+// Verify check code, attempt is counted before compare
+func (x *otpService) Verify(req OTPVerify) error {
+
+	row, err := x.find(req)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	switch {
+	case row.UsedAt != nil:
+		return ErrOTPUsed
+	case !now.Before(row.ExpiresAt):
+		return ErrOTPExpired
+	case row.Attempts >= row.MaxAttempts:
+		return ErrOTPAttemptsExceeded
+	}
+
+	// conditional update, concurrent attempts can not exceed max
+	tx := x.repository.Model(&OtpCode{}).
+		Where("id = ? and used_at is null and attempts < max_attempts", row.ID).
+		Update("attempts", gorm.Expr("attempts + 1"))
+
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrOTPAttemptsExceeded
+	}
+
+	if !hmac.Equal([]byte(otpHash(x.config.Pepper, row.Salt, req.Code)), []byte(row.Hash)) {
+		return ErrOTPInvalid
+	}
+
+	tx = x.repository.Model(&OtpCode{}).Where("id = ? and used_at is null", row.ID).Update("used_at", now)
+
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrOTPUsed
+	}
+
+	return nil
+}
+
+func (x *otpService) find(req OTPVerify) (*OtpCode, error) {
+
+	row := &OtpCode{}
+
+	var err error
+
+	switch {
+	case req.ID != "":
+		err = x.repository.Where("id = ?", req.ID).First(row).Error
+	case req.Channel != "" && req.To != "":
+		err = x.repository.Where("channel = ? and \"to\" = ?", req.Channel, req.To).
+			Order("created_at desc").First(row).Error
+	default:
+		return nil, ErrOTPNotFound
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOTPNotFound
+	}
+
+	return row, err
+}
+
+// generateOTP uniform random code of alphabet chars
+func generateOTP(length int, alphabet string) (string, error) {
+
+	chars := []rune(alphabet)
+	res := make([]rune, length)
+	n := big.NewInt(int64(len(chars)))
+
+	for i := range res {
+		v, err := rand.Int(rand.Reader, n)
+		if err != nil {
+			return "", err
+		}
+		res[i] = chars[v.Int64()]
+	}
+
+	return string(res), nil
+}
+
+// otpHash hmac of salt and code keyed by server side pepper, not stored with hashes
+func otpHash(pepper string, salt string, code string) string {
+	h := hmac.New(sha256.New, []byte(pepper))
+	h.Write([]byte(salt)) // fixed length
+	h.Write([]byte(code))
+	return hex.EncodeToString(h.Sum(nil))
+}
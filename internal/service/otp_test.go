@@ -0,0 +1,56 @@
+package service
+
+import (
+	"go-infra/internal/config"
+	"strings"
+	"testing"
+)
+
+// Test code length and alphabet
+func TestGenerateOTP(t *testing.T) {
+	seen := map[string]bool{}
+
+	for i := 0; i < 100; i++ {
+		code, err := generateOTP(8, "ABC123")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != 8 || strings.Trim(code, "ABC123") != "" {
+			t.Fatalf("Unexpected code %q", code)
+		}
+		seen[code] = true
+	}
+
+	if len(seen) < 90 {
+		t.Errorf("Expected random codes, got %d unique of 100", len(seen))
+	}
+}
+
+// Test hash depends on pepper, salt and code
+func TestOTPHash(t *testing.T) {
+	if otpHash("p", "s1", "123456") != otpHash("p", "s1", "123456") {
+		t.Error("Expected same hash")
+	}
+	if otpHash("p", "s1", "123456") == otpHash("p", "s2", "123456") ||
+		otpHash("p", "s1", "123456") == otpHash("p", "s1", "123457") ||
+		otpHash("p", "s1", "123456") == otpHash("q", "s1", "123456") {
+		t.Error("Expected different hash")
+	}
+	if strings.Contains(otpHash("p", "s1", "123456"), "123456") {
+		t.Error("Code in hash")
+	}
+}
+
+// Test bad config panics
+func TestNewOTPService_BadConfig(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic")
+		}
+	}()
+
+	appConfig := &config.AppConfig{}
+	appConfig.Messenger.OTP = config.AppConfigOTP{Length: 6, Alphabet: "1", TTL: 60, MaxAttempts: 3}
+
+	NewOTPService(appConfig, nil)
+}
@@ -0,0 +1,511 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-infra/internal/config"
+	"go-infra/internal/repository"
+	xlog "go-infra/internal/util/utillog"
+	"go-infra/internal/util/utiltaskqueue"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errOutboxMasked message with secrets masked in payload can not be sent from outbox
+var errOutboxMasked = errors.New("secrets of message are not stored, message is not sent again")
+
+// outbox message statuses
+const (
+	OutboxStatusQueued  = "queued"
+	OutboxStatusSending = "sending"
+	OutboxStatusSent    = "sent"
+	OutboxStatusFailed  = "failed"
+	OutboxStatusExpired = "expired"
+
+	// scheduled delivery, released to queue by send time or canceled
+	OutboxStatusScheduled = "scheduled"
+	OutboxStatusCanceled  = "canceled"
+
+	// by delivery receipt
+	OutboxStatusDelivered   = "delivered"
+	OutboxStatusUndelivered = "undelivered"
+	OutboxStatusBounced     = "bounced"
+)
+
+// outbox channels
+const (
+	ChannelSms   = "sms"
+	ChannelEmail = "email"
+	ChannelChat  = "chat"
+)
+
+// OutboxMessage durable copy of message, written before message is acknowledged
+type OutboxMessage struct {
+	ID        string `gorm:"primaryKey;size:32"`
+	Channel   string `gorm:"size:16;index:idx_outbox_channel_status"`
+	Status    string `gorm:"size:16;index:idx_outbox_channel_status"`
+	Payload   string // json of SmsMessage or EmailMessage
+	Masked    bool   // payload has secrets masked, message is lost if not sent by replica enqueued it
+	Attempts  int
+	LastError string
+	Gateway   string `gorm:"size:64"` // last gateway tried
+	// provider message id and status from delivery receipt
+	ProviderID     string     `gorm:"size:128;index"`
+	ProviderStatus string     `gorm:"size:64"`
+	SendAt         *time.Time `gorm:"index"` // scheduled delivery
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+
+	// replica holding pending message in its send queue until lease is expired
+	Owner      string `gorm:"size:64;index"`
+	LeaseUntil *time.Time
+}
+
+// OutboxAttempt single send attempt of message via gateway
+type OutboxAttempt struct {
+	ID        int64  `gorm:"primaryKey"`
+	MessageID string `gorm:"size:32;index"`
+	Channel   string `gorm:"size:16"`
+	Gateway   string `gorm:"size:64;index"`
+	Error     string
+	CreatedAt time.Time
+}
+
+// expiredReason reason of drop if createdAt+maxAge (seconds) is passed, empty if not expired or maxAge <= 0
+func expiredReason(createdAt time.Time, maxAge int16, now time.Time) string {
+
+	if maxAge <= 0 || createdAt.IsZero() {
+		return ""
+	}
+
+	age := now.Sub(createdAt)
+
+	if age <= time.Duration(maxAge)*time.Second {
+		return ""
+	}
+
+	return fmt.Sprintf("expired: age %v exceeds max age %vs", age.Round(time.Second), maxAge)
+}
+
+// scheduleInterval check interval of scheduled messages
+func scheduleInterval(appConfig *config.AppConfig) time.Duration {
+	return time.Duration(max(appConfig.Messenger.ScheduleInterval, 1)) * time.Second
+}
+
+// outboxLease lease of pending messages, longer than schedule interval renewing it
+func outboxLease(appConfig *config.AppConfig) time.Duration {
+	return max(time.Duration(appConfig.Messenger.OutboxLease)*time.Second, 3*scheduleInterval(appConfig))
+}
+
+// newOutboxOwner id of replica, host name if known
+func newOutboxOwner() string {
+	host, _ := os.Hostname()
+	if len(host) > 40 {
+		host = host[:40]
+	}
+	return host + "-" + newMessageID()[:16]
+}
+
+// isScheduled send time is in future
+func isScheduled(sendAt time.Time, now time.Time) bool {
+	return sendAt.After(now)
+}
+
+// maxAgeFrom max age of scheduled message counts from send time
+func maxAgeFrom(createdAt time.Time, sendAt time.Time) time.Time {
+	if sendAt.After(createdAt) {
+		return sendAt
+	}
+	return createdAt
+}
+
+func newMessageID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// outboxBatchSize rows per insert statement of batch
+const outboxBatchSize = 500
+
+// outboxDueLimit scheduled messages released by one run
+const outboxDueLimit = 1000
+
+type outbox struct {
+	channel    string
+	repository repository.AppRepository
+	owner      string        // replica id
+	lease      time.Duration // pending messages of owner
+}
+
+// leaseUntil lease of pending message from now
+func (x outbox) leaseUntil(now time.Time) *time.Time {
+	res := now.Add(x.lease)
+	return &res
+}
+
+// row outbox row of message, status scheduled if sendAt is in future, queued message is owned by replica
+func (x outbox) row(id string, message any, sendAt time.Time) (OutboxMessage, error) {
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		return OutboxMessage{}, err
+	}
+
+	res := OutboxMessage{
+		ID:      id,
+		Channel: x.channel,
+		Status:  OutboxStatusQueued,
+		Payload: string(data),
+	}
+
+	if isScheduled(sendAt, time.Now()) {
+		res.Status = OutboxStatusScheduled
+		res.SendAt = &sendAt
+	} else {
+		res.Owner = x.owner
+		res.LeaseUntil = x.leaseUntil(time.Now())
+	}
+
+	return res, nil
+}
+
+// add write message row
+func (x outbox) add(row OutboxMessage) error {
+	return x.repository.Create(&row).Error
+}
+
+// addBatch write message rows by one transaction
+func (x outbox) addBatch(rows []OutboxMessage) error {
+
+	return x.repository.Transaction(func(tx repository.AppRepository) error {
+		return tx.Driver().CreateInBatches(rows, outboxBatchSize).Error
+	})
+}
+
+// failedBatch mark messages as failed, batch is not enqueued
+func (x outbox) failedBatch(ids []string, reason string) error {
+
+	return x.repository.Model(&OutboxMessage{}).Where("id IN ?", ids).Updates(map[string]any{
+		"status":     OutboxStatusFailed,
+		"last_error": reason,
+	}).Error
+}
+
+// sending mark message as in progress and count attempt
+func (x outbox) sending(id string) error {
+
+	return x.repository.Model(&OutboxMessage{}).Where("id = ?", id).Updates(map[string]any{
+		"status":      OutboxStatusSending,
+		"attempts":    gorm.Expr("attempts + 1"),
+		"owner":       x.owner,
+		"lease_until": x.leaseUntil(time.Now()),
+	}).Error
+}
+
+// attempt record send attempt via gateway
+func (x outbox) attempt(id string, gateway string, providerID string, sendErr error) error {
+
+	row := &OutboxAttempt{
+		MessageID: id,
+		Channel:   x.channel,
+		Gateway:   gateway,
+	}
+
+	if sendErr != nil {
+		row.Error = sendErr.Error()
+	}
+
+	if err := x.repository.Create(row).Error; err != nil {
+		return err
+	}
+
+	values := map[string]any{"gateway": gateway}
+	if providerID != "" {
+		values["provider_id"] = providerID
+	}
+
+	return x.repository.Model(&OutboxMessage{}).Where("id = ?", id).Updates(values).Error
+}
+
+// sent mark message as sent, status set by delivery receipt meanwhile is kept
+func (x outbox) sent(id string) error {
+
+	return x.repository.Model(&OutboxMessage{}).Where("id = ? and status in ?",
+		id, []string{OutboxStatusQueued, OutboxStatusSending},
+	).Updates(map[string]any{
+		"status":     OutboxStatusSent,
+		"last_error": "",
+	}).Error
+}
+
+// retry mark message as queued again after failed attempt
+func (x outbox) retry(id string, sendErr error) error {
+
+	return x.repository.Model(&OutboxMessage{}).Where("id = ?", id).Updates(map[string]any{
+		"status":     OutboxStatusQueued,
+		"last_error": sendErr.Error(),
+	}).Error
+}
+
+// failed mark message as failed, no more attempts
+func (x outbox) failed(id string, reason string) error {
+
+	return x.repository.Model(&OutboxMessage{}).Where("id = ?", id).Updates(map[string]any{
+		"status":     OutboxStatusFailed,
+		"last_error": reason,
+	}).Error
+}
+
+// expired mark message as dropped by max age
+func (x outbox) expired(id string, reason string) error {
+
+	return x.repository.Model(&OutboxMessage{}).Where("id = ?", id).Updates(map[string]any{
+		"status":     OutboxStatusExpired,
+		"last_error": reason,
+	}).Error
+}
+
+// receipt apply delivery receipt of gateway, false if message not found
+func (x outbox) receipt(gateway string, receipt gatewayReceipt) (bool, error) {
+
+	row := OutboxMessage{}
+
+	err := x.repository.Where("channel = ? and gateway = ? and provider_id = ?",
+		x.channel, gateway, receipt.ProviderID,
+	).First(&row).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	err = x.repository.Model(&OutboxMessage{}).Where("id = ?", row.ID).
+		Update("provider_status", receipt.ProviderStatus).Error
+	if err != nil {
+		return true, err
+	}
+
+	overridden := receiptOverridden(receipt.Status)
+	if len(overridden) == 0 {
+		return true, nil
+	}
+
+	values := map[string]any{"status": receipt.Status}
+	if receipt.Error != "" {
+		values["last_error"] = receipt.Error
+	}
+
+	// by status condition, not by status read above, sent and receipts may race
+	return true, x.repository.Model(&OutboxMessage{}).Where("id = ? and status in ?", row.ID, overridden).
+		Updates(values).Error
+}
+
+// due take scheduled messages with send time passed, status is changed to queued and owned by replica,
+// rows locked by other replica are skipped
+func (x outbox) due(now time.Time, limit int) ([]OutboxMessage, error) {
+
+	res := []OutboxMessage{}
+
+	err := x.repository.Transaction(func(tx repository.AppRepository) error {
+
+		err := tx.Driver().Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("channel = ? and status = ? and send_at <= ?", x.channel, OutboxStatusScheduled, now).
+			Order("send_at").Limit(limit).Find(&res).Error
+		if err != nil || len(res) == 0 {
+			return err
+		}
+
+		ids := make([]string, 0, len(res))
+		for _, itm := range res {
+			ids = append(ids, itm.ID)
+		}
+
+		return tx.Model(&OutboxMessage{}).Where("id in ?", ids).Updates(map[string]any{
+			"status":      OutboxStatusQueued,
+			"owner":       x.owner,
+			"lease_until": x.leaseUntil(now),
+		}).Error
+	})
+
+	return res, err
+}
+
+// unclaim return messages not enqueued to status they were taken in, lease is dropped,
+// scheduled ones are released again by due and pending ones are claimed by any replica
+func (x outbox) unclaim(rows []OutboxMessage) error {
+
+	ids := map[string][]string{}
+	for _, itm := range rows {
+		ids[itm.Status] = append(ids[itm.Status], itm.ID)
+	}
+
+	return x.repository.Transaction(func(tx repository.AppRepository) error {
+		for status, list := range ids {
+			err := tx.Model(&OutboxMessage{}).Where("id in ? and owner = ?", list, x.owner).Updates(map[string]any{
+				"status":      status,
+				"owner":       "",
+				"lease_until": nil,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// renew lease of pending messages of replica
+func (x outbox) renew(now time.Time) error {
+
+	return x.repository.Model(&OutboxMessage{}).Where("channel = ? and owner = ? and status in ?",
+		x.channel, x.owner, []string{OutboxStatusQueued, OutboxStatusSending},
+	).Update("lease_until", x.leaseUntil(now)).Error
+}
+
+// claim take pending messages with lease expired, left by stopped replica or not finished before restart,
+// message in sending may have reached provider and is sent again only after its lease is expired,
+// status is changed to queued, rows locked by other replica are skipped
+func (x outbox) claim(now time.Time, limit int) ([]OutboxMessage, error) {
+
+	res := []OutboxMessage{}
+
+	err := x.repository.Transaction(func(tx repository.AppRepository) error {
+
+		err := tx.Driver().Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("channel = ? and status in ? and (lease_until is null or lease_until < ?)",
+				x.channel, []string{OutboxStatusQueued, OutboxStatusSending}, now,
+			).Order("created_at").Limit(limit).Find(&res).Error
+		if err != nil || len(res) == 0 {
+			return err
+		}
+
+		ids := make([]string, 0, len(res))
+		for _, itm := range res {
+			ids = append(ids, itm.ID)
+		}
+
+		return tx.Model(&OutboxMessage{}).Where("id in ?", ids).Updates(map[string]any{
+			"status":      OutboxStatusQueued,
+			"owner":       x.owner,
+			"lease_until": x.leaseUntil(now),
+		}).Error
+	})
+
+	return res, err
+}
+
+// outboxDeadLetters dead letter store over outbox rows with status failed
+type outboxDeadLetters[T any] struct {
+	outbox outbox
+	limit  int
+}
+
+// Add mark message as failed
+func (x outboxDeadLetters[T]) Add(item utiltaskqueue.DeadLetter[T]) {
+
+	if err := x.outbox.failed(item.ID, item.Error); err != nil {
+		xlog.Error("%v outbox %v: %v", x.outbox.channel, item.ID, err)
+	}
+}
+
+// List failed messages, latest first
+func (x outboxDeadLetters[T]) List() []utiltaskqueue.DeadLetter[T] {
+
+	rows := []OutboxMessage{}
+
+	err := x.outbox.repository.Where("channel = ? and status = ?",
+		x.outbox.channel, OutboxStatusFailed,
+	).Order("updated_at desc").Limit(x.limit).Find(&rows).Error
+
+	if err != nil {
+		xlog.Error("%v outbox dead letters: %v", x.outbox.channel, err)
+	}
+
+	res := make([]utiltaskqueue.DeadLetter[T], 0, len(rows))
+
+	for _, row := range rows {
+		res = append(res, x.deadLetter(row))
+	}
+
+	return res
+}
+
+// Take mark failed message as queued and return it
+func (x outboxDeadLetters[T]) Take(id string) (utiltaskqueue.DeadLetter[T], bool) {
+
+	row := OutboxMessage{}
+
+	err := x.outbox.repository.Where("id = ? and channel = ? and status = ?",
+		id, x.outbox.channel, OutboxStatusFailed,
+	).First(&row).Error
+
+	if err != nil {
+		return utiltaskqueue.DeadLetter[T]{}, false
+	}
+
+	res := x.deadLetter(row)
+	if res.Data == nil || row.Masked {
+		return res, false
+	}
+
+	err = x.outbox.repository.Model(&OutboxMessage{}).Where("id = ? and status = ?",
+		id, OutboxStatusFailed,
+	).Updates(map[string]any{
+		"status":      OutboxStatusQueued,
+		"attempts":    0,
+		"owner":       x.outbox.owner,
+		"lease_until": x.outbox.leaseUntil(time.Now()),
+	}).Error
+
+	if err != nil {
+		xlog.Error("%v outbox %v: %v", x.outbox.channel, id, err)
+		return res, false
+	}
+
+	return res, true
+}
+
+// Len count of failed messages
+func (x outboxDeadLetters[T]) Len() int {
+
+	var res int64
+
+	err := x.outbox.repository.Model(&OutboxMessage{}).Where("channel = ? and status = ?",
+		x.outbox.channel, OutboxStatusFailed,
+	).Count(&res).Error
+
+	if err != nil {
+		xlog.Error("%v outbox dead letters: %v", x.outbox.channel, err)
+	}
+
+	return int(res)
+}
+
+func (x outboxDeadLetters[T]) deadLetter(row OutboxMessage) utiltaskqueue.DeadLetter[T] {
+
+	res := utiltaskqueue.DeadLetter[T]{
+		ID:       row.ID,
+		Error:    row.LastError,
+		Attempts: row.Attempts,
+		FailedAt: row.UpdatedAt,
+	}
+
+	data := new(T)
+	if err := json.Unmarshal([]byte(row.Payload), data); err != nil {
+		xlog.Error("%v outbox %v: %v", x.outbox.channel, row.ID, fmt.Errorf("payload: %v", err))
+		return res
+	}
+
+	res.Data = data
+
+	return res
+}
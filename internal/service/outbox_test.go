@@ -0,0 +1,71 @@
+package service
+
+import (
+	"go-infra/internal/config"
+	"testing"
+	"time"
+)
+
+func TestOutboxRow_Scheduled(t *testing.T) {
+
+	box := outbox{channel: ChannelSms, owner: "replica-1", lease: time.Minute}
+
+	row, err := box.row("1", SmsMessage{Envelope: Envelope{To: "+10000000000"}}, time.Time{})
+	if err != nil || row.Status != OutboxStatusQueued || row.SendAt != nil {
+		t.Errorf("Expected queued row, got %v %v %v", row.Status, row.SendAt, err)
+	}
+
+	// queued message is held by replica, not claimed by other one until lease is expired
+	if row.Owner != "replica-1" || row.LeaseUntil == nil || !row.LeaseUntil.After(time.Now()) {
+		t.Errorf("Expected queued row owned by replica, got %v %v", row.Owner, row.LeaseUntil)
+	}
+
+	row, _ = box.row("2", SmsMessage{}, time.Now().Add(-time.Minute))
+	if row.Status != OutboxStatusQueued {
+		t.Errorf("Expected past send time to be queued, got %v", row.Status)
+	}
+
+	sendAt := time.Now().Add(time.Hour)
+
+	row, _ = box.row("3", SmsMessage{}, sendAt)
+	if row.Status != OutboxStatusScheduled || row.SendAt == nil || !row.SendAt.Equal(sendAt) {
+		t.Errorf("Expected scheduled row, got %v %v", row.Status, row.SendAt)
+	}
+	if row.Owner != "" || row.LeaseUntil != nil {
+		t.Errorf("Expected scheduled row without owner, got %v %v", row.Owner, row.LeaseUntil)
+	}
+}
+
+func TestOutboxLease(t *testing.T) {
+
+	appConfig := config.NewAppConfig()
+
+	if lease := outboxLease(appConfig); lease != 60*time.Second {
+		t.Errorf("Expected default lease 60s, got %v", lease)
+	}
+
+	// lease is renewed every schedule interval and must outlive several of them
+	appConfig.Messenger.OutboxLease = 1
+	if lease := outboxLease(appConfig); lease != 3*scheduleInterval(appConfig) {
+		t.Errorf("Expected lease of 3 schedule intervals, got %v", lease)
+	}
+
+	if a, b := newOutboxOwner(), newOutboxOwner(); a == b || len(a) > 64 {
+		t.Errorf("Expected unique owner ids, got %v %v", a, b)
+	}
+}
+
+func TestExpiredReason_Scheduled(t *testing.T) {
+
+	now := time.Now()
+	createdAt := now.Add(-time.Hour)
+
+	if expiredReason(maxAgeFrom(createdAt, time.Time{}), 30, now) == "" {
+		t.Error("Expected expired message")
+	}
+
+	// max age counts from send time of scheduled message
+	if reason := expiredReason(maxAgeFrom(createdAt, now.Add(-10*time.Second)), 30, now); reason != "" {
+		t.Errorf("Expected not expired, got %v", reason)
+	}
+}
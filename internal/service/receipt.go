@@ -0,0 +1,216 @@
+package service
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-infra/internal/config"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrReceiptForbidden webhook token not matched or webhook disabled
+var ErrReceiptForbidden = errors.New("receipt webhook forbidden")
+
+// ReceiptResult result of receipts apply
+type ReceiptResult struct {
+	Updated   int `json:"updated"`
+	Unmatched int `json:"unmatched"`
+}
+
+// gatewayReceipt single delivery receipt
+type gatewayReceipt struct {
+	ProviderID     string
+	ProviderStatus string
+	Status         string // outbox status, empty if provider status not mapped
+	Error          string
+}
+
+// localFinalRank rank of statuses set by sender, not by receipts
+const localFinalRank = 10
+
+// receiptStatusRank final statuses can not be changed by late or repeated receipts,
+// local final statuses outrank every receipt status
+var receiptStatusRank = map[string]int{
+	OutboxStatusScheduled:   0,
+	OutboxStatusQueued:      0,
+	OutboxStatusSending:     0,
+	OutboxStatusSent:        1,
+	OutboxStatusDelivered:   2,
+	OutboxStatusUndelivered: 3,
+	OutboxStatusBounced:     3,
+	OutboxStatusFailed:      localFinalRank,
+	OutboxStatusExpired:     localFinalRank,
+	OutboxStatusCanceled:    localFinalRank,
+}
+
+// receiptOverridden statuses replaced by receipt status, none if status is not of receipt
+func receiptOverridden(status string) []string {
+
+	res := []string{}
+
+	rank, ok := receiptStatusRank[status]
+	if !ok || rank >= localFinalRank {
+		return res
+	}
+
+	for itm, v := range receiptStatusRank {
+		if v < rank {
+			res = append(res, itm)
+		}
+	}
+
+	slices.Sort(res)
+
+	return res
+}
+
+// receiptConfig receipt config of gateway by name
+func receiptConfig(appConfig *config.AppConfig, channel string, gateway string) (config.AppConfigGatewayReceipt, bool) {
+
+	for _, cfg := range channelConfigs(appConfig) {
+
+		if cfg.Name != channel {
+			continue
+		}
+
+		for _, gw := range cfg.Gateways {
+			if gw.Name == "" {
+				gw.Name = DefaultGatewayName
+			}
+			if gw.Name == gateway {
+				return gw.Receipt, true
+			}
+		}
+	}
+
+	return config.AppConfigGatewayReceipt{}, false
+}
+
+// receiptTokenValid constant time compare, webhook disabled if token is not configured
+func receiptTokenValid(cfg config.AppConfigGatewayReceipt, token string) bool {
+	if cfg.Token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cfg.Token), []byte(token)) == 1
+}
+
+// parseReceipts receipts from decoded json or form values
+func parseReceipts(cfg config.AppConfigGatewayReceipt, data any) []gatewayReceipt {
+
+	if cfg.Items != "" {
+		data, _ = valuePath(data, cfg.Items)
+	}
+
+	items, ok := data.([]any)
+	if !ok {
+		items = []any{data}
+	}
+
+	res := make([]gatewayReceipt, 0, len(items))
+
+	for _, itm := range items {
+
+		id := pathString(itm, cfg.IDField)
+		if id == "" {
+			continue
+		}
+
+		providerStatus := pathString(itm, cfg.StatusField)
+
+		res = append(res, gatewayReceipt{
+			ProviderID:     id,
+			ProviderStatus: providerStatus,
+			Status:         receiptStatus(cfg, providerStatus),
+			Error:          pathString(itm, cfg.ErrorField),
+		})
+	}
+
+	return res
+}
+
+// receiptStatus map provider status, provider status is used as is if it is known outbox status
+func receiptStatus(cfg config.AppConfigGatewayReceipt, providerStatus string) string {
+
+	status, ok := cfg.Statuses[providerStatus]
+	if !ok {
+		status = strings.ToLower(providerStatus)
+	}
+
+	switch status {
+	case OutboxStatusSent, OutboxStatusDelivered, OutboxStatusUndelivered, OutboxStatusBounced:
+		return status
+	}
+
+	return ""
+}
+
+// responseID provider message id from gateway response
+func responseID(gw config.AppConfigMessageGateway, respBody []byte) string {
+
+	if gw.ResponseID == "" || len(respBody) == 0 {
+		return ""
+	}
+
+	// numbers as json.Number, ids above 2^53 are kept exact
+	dec := json.NewDecoder(bytes.NewReader(respBody))
+	dec.UseNumber()
+
+	var data any
+	if err := dec.Decode(&data); err != nil {
+		return ""
+	}
+
+	return pathString(data, gw.ResponseID)
+}
+
+// valuePath value by dotted path, numbers are list indexes: messages.0.id
+func valuePath(data any, path string) (any, bool) {
+
+	if path == "" {
+		return nil, false
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		switch v := data.(type) {
+		case map[string]any:
+			itm, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			data = itm
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			data = v[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return data, true
+}
+
+func pathString(data any, path string) string {
+
+	v, ok := valuePath(data, path)
+	if !ok || v == nil {
+		return ""
+	}
+
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		return v.String()
+	}
+
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"encoding/json"
+	"go-infra/internal/config"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Test provider message id from gateway response
+func TestResponseID(t *testing.T) {
+	gw := config.AppConfigMessageGateway{ResponseID: "messages.0.id"}
+
+	if id := responseID(gw, []byte(`{"messages":[{"id":"abc-1"}]}`)); id != "abc-1" {
+		t.Errorf("Expected abc-1, got %q", id)
+	}
+	if id := responseID(gw, []byte(`{"messages":[{"id":12345}]}`)); id != "12345" {
+		t.Errorf("Expected 12345, got %q", id)
+	}
+	if id := responseID(gw, []byte(`{"messages":[{"id":9007199254740993}]}`)); id != "9007199254740993" {
+		t.Errorf("Expected exact large numeric id, got %q", id)
+	}
+	if id := responseID(gw, []byte(`not json`)); id != "" {
+		t.Errorf("Expected empty id, got %q", id)
+	}
+	if id := responseID(config.AppConfigMessageGateway{}, []byte(`{"id":"x"}`)); id != "" {
+		t.Errorf("Expected empty id without response_id, got %q", id)
+	}
+}
+
+// Test receipt fields mapping and status mapping
+func TestParseReceipts(t *testing.T) {
+	cfg := config.AppConfigGatewayReceipt{
+		Items:       "events",
+		IDField:     "msg.id",
+		StatusField: "status",
+		ErrorField:  "reason",
+		Statuses:    map[string]string{"DELIVRD": OutboxStatusDelivered, "UNDELIV": OutboxStatusUndelivered},
+	}
+
+	var data any
+	_ = json.Unmarshal([]byte(`{"events":[
+		{"msg":{"id":"a"},"status":"DELIVRD"},
+		{"msg":{"id":"b"},"status":"UNDELIV","reason":"absent subscriber"},
+		{"msg":{"id":"c"},"status":"bounced"},
+		{"msg":{"id":"d"},"status":"ENROUTE"},
+		{"status":"DELIVRD"}
+	]}`), &data)
+
+	res := parseReceipts(cfg, data)
+
+	expected := []gatewayReceipt{
+		{ProviderID: "a", ProviderStatus: "DELIVRD", Status: OutboxStatusDelivered},
+		{ProviderID: "b", ProviderStatus: "UNDELIV", Status: OutboxStatusUndelivered, Error: "absent subscriber"},
+		{ProviderID: "c", ProviderStatus: "bounced", Status: OutboxStatusBounced},
+		{ProviderID: "d", ProviderStatus: "ENROUTE"},
+	}
+
+	if len(res) != len(expected) {
+		t.Fatalf("Expected %d receipts, got %+v", len(expected), res)
+	}
+	for i := range expected {
+		if res[i] != expected[i] {
+			t.Errorf("Receipt %d: expected %+v, got %+v", i, expected[i], res[i])
+		}
+	}
+
+	// single form receipt
+	res = parseReceipts(config.AppConfigGatewayReceipt{IDField: "id", StatusField: "stat"},
+		map[string]any{"id": "x", "stat": "delivered"})
+	if len(res) != 1 || res[0].Status != OutboxStatusDelivered {
+		t.Errorf("Unexpected form receipt: %+v", res)
+	}
+}
+
+// Test webhook token and gateway lookup
+func TestReceiptConfig(t *testing.T) {
+	appConfig := &config.AppConfig{
+		SmsGateway: config.AppConfigMessageGateway{Receipt: config.AppConfigGatewayReceipt{Token: "secret"}},
+		EmailGateways: []config.AppConfigMessageGateway{
+			{Name: "a", Receipt: config.AppConfigGatewayReceipt{Token: "a-secret"}},
+			{Name: "b"},
+		},
+	}
+
+	cfg, ok := receiptConfig(appConfig, ChannelSms, DefaultGatewayName)
+	if !ok || !receiptTokenValid(cfg, "secret") || receiptTokenValid(cfg, "wrong") {
+		t.Errorf("Unexpected default gateway receipt config: %+v", cfg)
+	}
+
+	cfg, ok = receiptConfig(appConfig, ChannelEmail, "a")
+	if !ok || !receiptTokenValid(cfg, "a-secret") {
+		t.Errorf("Unexpected gateway a receipt config: %+v", cfg)
+	}
+
+	cfg, ok = receiptConfig(appConfig, ChannelEmail, "b")
+	if !ok || receiptTokenValid(cfg, "") {
+		t.Error("Expected webhook disabled without token")
+	}
+
+	if _, ok := receiptConfig(appConfig, ChannelEmail, DefaultGatewayName); ok {
+		t.Error("Expected default gateway not exists if gateways list is set")
+	}
+}
+
+// Test late receipts do not override final statuses
+func TestReceiptOverridden(t *testing.T) {
+
+	for status, expected := range map[string]string{
+		OutboxStatusSent:        "queued,scheduled,sending",
+		OutboxStatusDelivered:   "queued,scheduled,sending,sent",
+		OutboxStatusBounced:     "delivered,queued,scheduled,sending,sent",
+		OutboxStatusUndelivered: "delivered,queued,scheduled,sending,sent",
+		OutboxStatusFailed:      "",
+		"":                      "",
+		"unknown":               "",
+	} {
+		if res := strings.Join(receiptOverridden(status), ","); res != expected {
+			t.Errorf("Status %q: expected %v, got %v", status, expected, res)
+		}
+	}
+
+	// failed, expired and canceled are kept by any receipt
+	for _, status := range []string{OutboxStatusFailed, OutboxStatusExpired, OutboxStatusCanceled} {
+		for _, receipt := range []string{OutboxStatusSent, OutboxStatusDelivered, OutboxStatusBounced} {
+			if slices.Contains(receiptOverridden(receipt), status) {
+				t.Errorf("Expected %v kept by %v receipt", status, receipt)
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"fmt"
+	"go-infra/internal/config"
+	"go-infra/internal/util/utilphone"
+	"net/mail"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// recipient validation error codes
+const (
+	RecipientInvalidPhone      = "invalid_phone"
+	RecipientPhoneDenied       = "phone_country_denied"
+	RecipientInvalidEmail      = "invalid_email"
+	RecipientEmailDomainDenied = "email_domain_denied"
+	RecipientUnknownRoom       = "unknown_room"
+)
+
+// RecipientError recipient is not valid, Code is for clients
+type RecipientError struct {
+	Code    string
+	Message string
+}
+
+func (e *RecipientError) Error() string { return e.Code + ": " + e.Message }
+
+// RecipientValidator normalize and validate recipients
+type RecipientValidator interface {
+	// Normalize E.164 phone for sms, ascii domain email for email, configured room for chat, *RecipientError if not valid
+	Normalize(channel string, to string) (string, error)
+}
+
+type recipientValidator struct {
+	config      config.AppConfigRecipients
+	denyDomains []string
+	chatRooms   map[string]config.AppConfigChatRoom
+}
+
+// NewRecipientValidator new validator, panic on bad config
+func NewRecipientValidator(appConfig *config.AppConfig) RecipientValidator {
+
+	cfg := appConfig.Messenger.Recipients
+
+	if cfg.PhoneDefaultRegion != "" && utilphone.RegionCode(cfg.PhoneDefaultRegion) == "" {
+		panic(fmt.Errorf("error phone default region not supported: %v", cfg.PhoneDefaultRegion))
+	}
+
+	res := &recipientValidator{config: cfg, chatRooms: appConfig.Messenger.ChatRooms}
+
+	for _, v := range cfg.EmailDenyDomains {
+		domain, err := idna.Lookup.ToASCII(strings.ToLower(strings.TrimSpace(v)))
+		if err != nil {
+			panic(fmt.Errorf("error email deny domain %v: %v", v, err))
+		}
+		res.denyDomains = append(res.denyDomains, domain)
+	}
+
+	return res
+}
+
+// Normalize by channel, other channels are not changed
+func (x *recipientValidator) Normalize(channel string, to string) (string, error) {
+
+	switch channel {
+	case ChannelSms:
+		return x.phone(to)
+	case ChannelEmail:
+		return x.email(to)
+	case ChannelChat:
+		return x.room(to)
+	}
+
+	return to, nil
+}
+
+func (x *recipientValidator) room(to string) (string, error) {
+
+	if _, ok := x.chatRooms[to]; !ok {
+		return "", &RecipientError{Code: RecipientUnknownRoom, Message: "chat room not configured: " + to}
+	}
+
+	return to, nil
+}
+
+func (x *recipientValidator) phone(to string) (string, error) {
+
+	res, err := utilphone.Normalize(to, x.config.PhoneDefaultRegion)
+	if err != nil {
+		return "", &RecipientError{Code: RecipientInvalidPhone, Message: err.Error()}
+	}
+
+	hasPrefix := func(v string) bool { return strings.HasPrefix(res, "+"+utilphone.Digits(v)) }
+
+	if len(x.config.PhoneAllowPrefix) > 0 && !slices.ContainsFunc(x.config.PhoneAllowPrefix, hasPrefix) {
+		return "", &RecipientError{Code: RecipientPhoneDenied, Message: "phone country is not allowed"}
+	}
+
+	if slices.ContainsFunc(x.config.PhoneDenyPrefix, hasPrefix) {
+		return "", &RecipientError{Code: RecipientPhoneDenied, Message: "phone country is denied"}
+	}
+
+	return res, nil
+}
+
+func (x *recipientValidator) email(to string) (string, error) {
+
+	invalid := func(message string) error {
+		return &RecipientError{Code: RecipientInvalidEmail, Message: message}
+	}
+
+	addr, err := mail.ParseAddress(strings.TrimSpace(to))
+	if err != nil {
+		return "", invalid(err.Error())
+	}
+
+	i := strings.LastIndex(addr.Address, "@")
+	if i <= 0 {
+		return "", invalid("domain is missing")
+	}
+
+	local, domain := addr.Address[:i], addr.Address[i+1:]
+
+	// IDN to punycode, length limits of RFC 5321
+	domain, err = idna.Lookup.ToASCII(strings.ToLower(domain))
+	if err != nil {
+		return "", invalid(fmt.Sprintf("domain: %v", err))
+	}
+
+	if !strings.Contains(domain, ".") || len(local) > 64 || len(local)+1+len(domain) > 254 {
+		return "", invalid("domain or length")
+	}
+
+	if slices.ContainsFunc(x.denyDomains, func(v string) bool {
+		return domain == v || strings.HasSuffix(domain, "."+v)
+	}) {
+		return "", &RecipientError{Code: RecipientEmailDomainDenied, Message: "email domain is denied"}
+	}
+
+	return local + "@" + domain, nil
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"errors"
+	"go-infra/internal/config"
+	"testing"
+)
+
+func newTestRecipientValidator(cfg config.AppConfigRecipients) RecipientValidator {
+	appConfig := &config.AppConfig{}
+	appConfig.Messenger.Recipients = cfg
+	return NewRecipientValidator(appConfig)
+}
+
+func recipientCode(err error) string {
+	var recipientErr *RecipientError
+	if errors.As(err, &recipientErr) {
+		return recipientErr.Code
+	}
+	return ""
+}
+
+// Test phone normalization and country lists
+func TestRecipientValidator_Phone(t *testing.T) {
+	x := newTestRecipientValidator(config.AppConfigRecipients{
+		PhoneDefaultRegion: "GB",
+		PhoneAllowPrefix:   []string{"+44", "+1"},
+		PhoneDenyPrefix:    []string{"+1876"},
+	})
+
+	cases := []struct {
+		to       string
+		expected string
+		code     string
+	}{
+		{"07700 900123", "+447700900123", ""},
+		{"+1 (202) 555-0100", "+12025550100", ""},
+		{"+49 30 1234567", "", RecipientPhoneDenied},
+		{"+1 876 555 0100", "", RecipientPhoneDenied},
+		{"abc", "", RecipientInvalidPhone},
+		{"12", "", RecipientInvalidPhone},
+	}
+
+	for _, c := range cases {
+		res, err := x.Normalize(ChannelSms, c.to)
+		if res != c.expected || recipientCode(err) != c.code {
+			t.Errorf("Normalize(%q): expected %q %q, got %q %v", c.to, c.expected, c.code, res, err)
+		}
+	}
+}
+
+// Test email syntax, IDN and disposable domains
+func TestRecipientValidator_Email(t *testing.T) {
+	x := newTestRecipientValidator(config.AppConfigRecipients{EmailDenyDomains: []string{"mailinator.com"}})
+
+	cases := []struct {
+		to       string
+		expected string
+		code     string
+	}{
+		{"User@Example.COM", "User@example.com", ""},
+		{"Имя <user@пример.рф>", "user@xn--e1afmkfd.xn--p1ai", ""},
+		{"user@mailinator.com", "", RecipientEmailDomainDenied},
+		{"user@eu.mailinator.com", "", RecipientEmailDomainDenied},
+		{"user@localhost", "", RecipientInvalidEmail},
+		{"user.example.com", "", RecipientInvalidEmail},
+		{"user@exa mple.com", "", RecipientInvalidEmail},
+	}
+
+	for _, c := range cases {
+		res, err := x.Normalize(ChannelEmail, c.to)
+		if res != c.expected || recipientCode(err) != c.code {
+			t.Errorf("Normalize(%q): expected %q %q, got %q %v", c.to, c.expected, c.code, res, err)
+		}
+	}
+}
+
+// Test chat room must be configured
+func TestRecipientValidator_Room(t *testing.T) {
+	appConfig := &config.AppConfig{}
+	appConfig.Messenger.ChatRooms = map[string]config.AppConfigChatRoom{"ops": {URL: "https://chat.example.com/hooks/x"}}
+	x := NewRecipientValidator(appConfig)
+
+	if res, err := x.Normalize(ChannelChat, "ops"); err != nil || res != "ops" {
+		t.Errorf("Unexpected %q, %v", res, err)
+	}
+
+	if _, err := x.Normalize(ChannelChat, "dev"); recipientCode(err) != RecipientUnknownRoom {
+		t.Errorf("Expected %v, got %v", RecipientUnknownRoom, err)
+	}
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"errors"
+	"go-infra/internal/config"
+	"go-infra/internal/util/utilhttp"
+	"go-infra/internal/util/utiltaskqueue"
+	"net/http"
+	"time"
+)
+
+// deadLettersLimit max dead letters returned by list
+const deadLettersLimit = 1000
+
+func newRetryPolicy(c config.AppConfigRetry) utiltaskqueue.RetryPolicy {
+	return utiltaskqueue.RetryPolicy{
+		MaxAttempts: c.MaxAttempts,
+		BaseDelay:   time.Duration(c.BaseDelay) * time.Millisecond,
+		MaxDelay:    time.Duration(c.MaxDelay) * time.Millisecond,
+		Jitter:      c.Jitter,
+		Retryable:   isRetryableSendError,
+	}
+}
+
+// isRetryableSendError gateway 5xx, 408, 429 and network errors are retryable, other 4xx are not
+func isRetryableSendError(err error) bool {
+
+	var statusErr *utilhttp.StatusError
+
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests:
+			return true
+		}
+		return statusErr.StatusCode >= 500
+	}
+
+	return true
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"container/list"
+	"go-infra/internal/config"
+	"sync"
+	"time"
+)
+
+// CapturedMessage message captured by sandbox gateway instead of send, content is not masked
+type CapturedMessage struct {
+	ID         string    `json:"id"`
+	MessageID  string    `json:"message_id"`
+	Channel    string    `json:"channel"`
+	Gateway    string    `json:"gateway"`
+	To         string    `json:"to"`
+	Message    any       `json:"message"`
+	CapturedAt time.Time `json:"captured_at"`
+}
+
+// SandboxFilter captured messages filter, empty fields match all
+type SandboxFilter struct {
+	Channel string
+	To      string
+	Limit   int // <= 0 all
+}
+
+// SandboxStore bounded in-memory store of sandbox gateways, oldest dropped on overflow
+type SandboxStore interface {
+	// List captured messages latest first
+	List(filter SandboxFilter) []CapturedMessage
+	// Get captured message by its id or message id, latest one
+	Get(id string) (CapturedMessage, bool)
+	// Clear drop captured messages of channel or all if empty, count dropped
+	Clear(channel string) int
+
+	capture(item CapturedMessage) string
+}
+
+type sandboxStore struct {
+	list    list.List
+	mu      sync.Mutex
+	maxSize int
+}
+
+// NewSandboxStore new store, size of messenger sandbox_size
+func NewSandboxStore(appConfig *config.AppConfig) SandboxStore {
+	return &sandboxStore{maxSize: appConfig.Messenger.SandboxSize}
+}
+
+// capture add message, provider id returned
+func (x *sandboxStore) capture(item CapturedMessage) string {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	item.ID = newMessageID()
+	item.CapturedAt = time.Now()
+
+	x.list.PushBack(item)
+
+	for x.maxSize > 0 && x.list.Len() > x.maxSize {
+		x.list.Remove(x.list.Front())
+	}
+
+	return item.ID
+}
+
+// List captured messages latest first
+func (x *sandboxStore) List(filter SandboxFilter) []CapturedMessage {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	res := []CapturedMessage{}
+
+	for el := x.list.Back(); el != nil; el = el.Prev() {
+
+		if filter.Limit > 0 && len(res) >= filter.Limit {
+			break
+		}
+
+		item := el.Value.(CapturedMessage)
+		if (filter.Channel == "" || item.Channel == filter.Channel) && (filter.To == "" || item.To == filter.To) {
+			res = append(res, item)
+		}
+	}
+
+	return res
+}
+
+// Get captured message by its id or message id, latest one
+func (x *sandboxStore) Get(id string) (CapturedMessage, bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	for el := x.list.Back(); el != nil; el = el.Prev() {
+		item := el.Value.(CapturedMessage)
+		if item.ID == id || item.MessageID == id {
+			return item, true
+		}
+	}
+
+	return CapturedMessage{}, false
+}
+
+// Clear drop captured messages of channel or all if empty, count dropped
+func (x *sandboxStore) Clear(channel string) int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	res := 0
+
+	for el := x.list.Front(); el != nil; {
+		next := el.Next()
+		if channel == "" || el.Value.(CapturedMessage).Channel == channel {
+			x.list.Remove(el)
+			res++
+		}
+		el = next
+	}
+
+	return res
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"go-infra/internal/config"
+	"testing"
+)
+
+// Test bounded capture, search and clear
+func TestSandboxStore(t *testing.T) {
+	appConfig := &config.AppConfig{}
+	appConfig.Messenger.SandboxSize = 3
+	x := NewSandboxStore(appConfig)
+
+	for _, itm := range []CapturedMessage{
+		{MessageID: "m1", Channel: ChannelSms, To: "+447700900001"},
+		{MessageID: "m2", Channel: ChannelSms, To: "+447700900002"},
+		{MessageID: "m3", Channel: ChannelEmail, To: "user@example.com"},
+		{MessageID: "m4", Channel: ChannelSms, To: "+447700900002", Message: SmsMessage{Text: "code 123456"}},
+	} {
+		x.capture(itm)
+	}
+
+	if res := x.List(SandboxFilter{}); len(res) != 3 || res[0].MessageID != "m4" || res[2].MessageID != "m2" {
+		t.Fatalf("Expected oldest dropped, latest first, got %+v", res)
+	}
+
+	if res := x.List(SandboxFilter{Channel: ChannelSms, To: "+447700900002", Limit: 1}); len(res) != 1 || res[0].MessageID != "m4" {
+		t.Errorf("Unexpected search result %+v", res)
+	}
+
+	res, ok := x.Get("m4")
+	if !ok || res.Message.(SmsMessage).Text != "code 123456" {
+		t.Errorf("Unexpected captured message %+v", res)
+	}
+	if byID, ok := x.Get(res.ID); !ok || byID.MessageID != "m4" {
+		t.Errorf("Expected captured message by own id")
+	}
+
+	if _, ok := x.Get("m1"); ok {
+		t.Error("Expected dropped message not found")
+	}
+
+	if n := x.Clear(ChannelSms); n != 2 || len(x.List(SandboxFilter{})) != 1 {
+		t.Errorf("Unexpected clear of channel: %v", n)
+	}
+}
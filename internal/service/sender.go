@@ -7,26 +7,36 @@ import (
 	"go-infra/internal/config"
 	"go-infra/internal/util/utilhttp"
 	xlog "go-infra/internal/util/utillog"
+	"go-infra/internal/util/utiltaskqueue"
 	"maps"
+	"regexp"
 	"slices"
 )
 
+// gateway body types
+const (
+	BodyTypeForm       = "form"        // {"to":"","text":""} keys as form fields
+	BodyTypeJSON       = "json"        // {"to":"","text":""} keys as json fields
+	BodyTypeJSONNested = "json_nested" // any json, {{name}} placeholders in strings
+)
+
+var bodyPlaceholder = regexp.MustCompile(`\{\{\s*([a-z_]+)\s*\}\}`)
+
 type dataSender struct {
 	QueryData   map[string]string
 	BodyForm    map[string]string
-	BodyJSON    map[string]string
+	BodyJSON    any
 	HeadersData map[string]string
 }
 
-func newDataSender() dataSender {
-	return dataSender{
+func newDataSender() *dataSender {
+	return &dataSender{
 		QueryData:   map[string]string{},
 		BodyForm:    map[string]string{},
-		BodyJSON:    map[string]string{},
 		HeadersData: map[string]string{},
 	}
 }
-func (sd dataSender) fillQuery(
+func (sd *dataSender) fillQuery(
 	gw config.AppConfigMessageGateway,
 	extract func(string) (string, error),
 ) error {
@@ -50,32 +60,120 @@ func (sd dataSender) fillQuery(
 
 }
 
-func (sd dataSender) fillBody(gw config.AppConfigMessageGateway,
+func (sd *dataSender) fillBody(gw config.AppConfigMessageGateway,
 	extract func(string) (string, error),
 ) error {
 
-	// email
-	if gw.Body != "" {
+	if gw.Body == "" {
+		return nil
+	}
+
+	switch bodyType(gw) {
+
+	case BodyTypeForm:
 
-		if err := json.Unmarshal([]byte(gw.Body), &sd.BodyJSON); err != nil {
+		if err := json.Unmarshal([]byte(gw.Body), &sd.BodyForm); err != nil {
 			return fmt.Errorf("body param: %v", err)
 		}
 
-		for _, key := range slices.Collect(maps.Keys(sd.BodyJSON)) {
+		for _, key := range slices.Collect(maps.Keys(sd.BodyForm)) {
 			val, err := extract(key)
 			if err != nil {
 				return err
 			}
-
 			sd.BodyForm[key] = val
 		}
+
+	case BodyTypeJSON:
+
+		keys := map[string]string{}
+		if err := json.Unmarshal([]byte(gw.Body), &keys); err != nil {
+			return fmt.Errorf("body param: %v", err)
+		}
+
+		body := map[string]any{}
+		for key := range keys {
+			val, err := extract(key)
+			if err != nil {
+				return err
+			}
+			body[key] = val
+		}
+
+		sd.BodyJSON = body
+
+	case BodyTypeJSONNested:
+
+		var body any
+		if err := json.Unmarshal([]byte(gw.Body), &body); err != nil {
+			return fmt.Errorf("body param: %v", err)
+		}
+
+		body, err := fillPlaceholders(body, extract)
+		if err != nil {
+			return err
+		}
+
+		sd.BodyJSON = body
+
+	default:
+		return fmt.Errorf("body type not supported: %v", gw.BodyType)
 	}
 
 	return nil
 
 }
 
-func (sd dataSender) sendData(gw config.AppConfigMessageGateway) error {
+// fillPlaceholders replace {{name}} in all strings of json value
+func fillPlaceholders(value any, extract func(string) (string, error)) (any, error) {
+
+	switch v := value.(type) {
+
+	case string:
+		var errRes error
+		res := bodyPlaceholder.ReplaceAllStringFunc(v, func(match string) string {
+			name := bodyPlaceholder.FindStringSubmatch(match)[1]
+			val, err := extract(name)
+			if err != nil && errRes == nil {
+				errRes = err
+			}
+			return val
+		})
+		return res, errRes
+
+	case []any:
+		for i := range v {
+			itm, err := fillPlaceholders(v[i], extract)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = itm
+		}
+		return v, nil
+
+	case map[string]any:
+		for key := range v {
+			itm, err := fillPlaceholders(v[key], extract)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = itm
+		}
+		return v, nil
+	}
+
+	return value, nil // numbers, bool, null as is
+}
+
+func bodyType(gw config.AppConfigMessageGateway) string {
+	if gw.BodyType == "" {
+		return BodyTypeForm
+	}
+	return gw.BodyType
+}
+
+// sendData send request, response body returned
+func (sd *dataSender) sendData(gw config.AppConfigMessageGateway) ([]byte, error) {
 
 	if gw.User != "" {
 		auth := gw.User + ":" + gw.Password
@@ -84,11 +182,18 @@ func (sd dataSender) sendData(gw config.AppConfigMessageGateway) error {
 
 	if gw.URL == "" {
 
-		return fmt.Errorf("error gateway URL is empty")
+		return nil, utiltaskqueue.Permanent(fmt.Errorf("error gateway URL is empty"))
 
 	}
 
-	respBody, err := utilhttp.PostFormURL(gw.URL, sd.QueryData, sd.HeadersData, sd.BodyForm)
+	var respBody []byte
+	var err error
+
+	if bodyType(gw) == BodyTypeForm {
+		respBody, err = utilhttp.PostFormURL(gw.URL, sd.QueryData, sd.HeadersData, sd.BodyForm)
+	} else {
+		respBody, err = utilhttp.PostJSON(gw.URL, sd.QueryData, sd.HeadersData, sd.BodyJSON)
+	}
 
 	if gw.Stdout {
 		if err != nil && len(respBody) > 0 {
@@ -96,5 +201,5 @@ func (sd dataSender) sendData(gw config.AppConfigMessageGateway) error {
 		}
 	}
 
-	return err
+	return respBody, err
 }
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"encoding/json"
+	"go-infra/internal/config"
+	"go-infra/internal/util/utiltaskqueue"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// captureServer http server saving last request
+func captureServer(t *testing.T, status int) (*httptest.Server, *http.Request, *[]byte) {
+	req := &http.Request{}
+	body := &[]byte{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*req = *r.Clone(r.Context())
+		*body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, req, body
+}
+
+// Test form body mode
+func TestDataSender_Form(t *testing.T) {
+	server, req, body := captureServer(t, http.StatusOK)
+
+	gw := config.AppConfigMessageGateway{URL: server.URL, Body: `{"to":"","text":""}`, BodyType: BodyTypeForm}
+	message := &SmsMessage{Envelope: Envelope{To: "+123121234567"}, Text: "code 12345678"}
+
+	sd := newDataSender()
+	if err := sd.fillBody(gw, message.exctractValueForSms); err != nil {
+		t.Fatalf("fillBody error: %v", err)
+	}
+	if _, err := sd.sendData(gw); err != nil {
+		t.Fatalf("sendData error: %v", err)
+	}
+
+	if req.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+		t.Errorf("Unexpected content type: %v", req.Header.Get("Content-Type"))
+	}
+	if string(*body) != "text=code+12345678&to=%2B123121234567" {
+		t.Errorf("Unexpected body: %s", *body)
+	}
+}
+
+// Test flat json body mode
+func TestDataSender_JSON(t *testing.T) {
+	server, req, body := captureServer(t, http.StatusOK)
+
+	gw := config.AppConfigMessageGateway{URL: server.URL, Body: `{"to":"","text":""}`, BodyType: BodyTypeJSON}
+	message := &SmsMessage{Envelope: Envelope{To: "+123121234567"}, Text: "code 12345678"}
+
+	sd := newDataSender()
+	if err := sd.fillBody(gw, message.exctractValueForSms); err != nil {
+		t.Fatalf("fillBody error: %v", err)
+	}
+	if _, err := sd.sendData(gw); err != nil {
+		t.Fatalf("sendData error: %v", err)
+	}
+
+	if req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected content type: %v", req.Header.Get("Content-Type"))
+	}
+
+	data := map[string]string{}
+	_ = json.Unmarshal(*body, &data)
+	if data["to"] != "+123121234567" || data["text"] != "code 12345678" {
+		t.Errorf("Unexpected body: %s", *body)
+	}
+
+	// 202 Accepted of queueing providers is success, not failover
+	accepted, _, _ := captureServer(t, http.StatusAccepted)
+	gw.URL = accepted.URL
+	if _, err := sd.sendData(gw); err != nil {
+		t.Errorf("Expected 202 as success, got %v", err)
+	}
+}
+
+// Test nested json body mode with objects and arrays
+func TestDataSender_JSONNested(t *testing.T) {
+	server, _, body := captureServer(t, http.StatusOK)
+
+	gw := config.AppConfigMessageGateway{
+		URL:      server.URL,
+		Body:     `{"personalizations":[{"to":[{"email":"{{to}}"}]}],"subject":"{{ subject }}","content":[{"type":"text/html","value":"{{html}}"}],"tracking":false,"priority":1}`,
+		BodyType: BodyTypeJSONNested,
+	}
+	message := &EmailMessage{Envelope: Envelope{To: "user@example.com"}, Subject: "Secret code", HTML: "<b>12345678</b>"}
+
+	sd := newDataSender()
+	if err := sd.fillBody(gw, message.exctractValueForEmail); err != nil {
+		t.Fatalf("fillBody error: %v", err)
+	}
+	if _, err := sd.sendData(gw); err != nil {
+		t.Fatalf("sendData error: %v", err)
+	}
+
+	expected := `{"content":[{"type":"text/html","value":"<b>12345678</b>"}],"personalizations":[{"to":[{"email":"user@example.com"}]}],"priority":1,"subject":"Secret code","tracking":false}`
+	var got, want any
+	_ = json.Unmarshal(*body, &got)
+	_ = json.Unmarshal([]byte(expected), &want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected body:\n%s\nexpected:\n%s", *body, expected)
+	}
+
+	accepted, _, _ := captureServer(t, http.StatusAccepted)
+	gw.URL = accepted.URL
+	if _, err := sd.sendData(gw); err != nil {
+		t.Errorf("Expected 202 as success, got %v", err)
+	}
+
+	gw.Body = `{"to":"{{phone}}"}`
+	if err := newDataSender().fillBody(gw, message.exctractValueForEmail); err == nil {
+		t.Error("Expected error for unknown placeholder")
+	}
+}
+
+// Test gateway status classification
+func TestDataSender_StatusError(t *testing.T) {
+	for status, retryable := range map[int]bool{
+		http.StatusBadGateway:      true,
+		http.StatusTooManyRequests: true,
+		http.StatusBadRequest:      false,
+		http.StatusUnauthorized:    false,
+	} {
+		server, _, _ := captureServer(t, status)
+
+		gw := config.AppConfigMessageGateway{URL: server.URL}
+		_, err := newDataSender().sendData(gw)
+		if err == nil {
+			t.Fatalf("Expected error for status %d", status)
+		}
+		if isRetryableSendError(err) != retryable {
+			t.Errorf("Status %d: expected retryable %v", status, retryable)
+		}
+	}
+}
+
+// Test templated gateway request with method, headers, consts and funcs
+func TestGatewayRequest_Send(t *testing.T) {
+	server, req, body := captureServer(t, http.StatusAccepted)
+
+	request, err := newGatewayRequest(config.AppConfigGatewayRequest{
+		Method: "put",
+		URL:    server.URL + `/v1/{{.Const.account}}/sms?to={{urlencode .Msg.to}}`,
+		Headers: map[string]string{
+			"Authorization": `{{basicauth .User .Password}}`,
+			"Content-Type":  "application/json",
+			"X-Text":        `{{base64 .Msg.text}}`,
+		},
+		Body:   `{"from":{{json .From}},"to":{{json .Msg.to}},"text":{{json .Msg.text}},"lang":{{json (default "en" .Msg.lang)}}}`,
+		Consts: map[string]string{"account": "acc1"},
+	})
+	if err != nil {
+		t.Fatalf("newGatewayRequest error: %v", err)
+	}
+
+	gw := config.AppConfigMessageGateway{From: "App", User: "user", Password: "secret"}
+	message := &SmsMessage{Envelope: Envelope{To: "+123121234567"}, Text: `code "12345678"`}
+
+	if _, err := request.send(gw, message.templateValues()); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+
+	if req.Method != http.MethodPut {
+		t.Errorf("Unexpected method: %v", req.Method)
+	}
+	if req.URL.Path != "/v1/acc1/sms" || req.URL.Query().Get("to") != "+123121234567" {
+		t.Errorf("Unexpected url: %v", req.URL)
+	}
+	if req.Header.Get("Authorization") != BasicAuth("user", "secret") {
+		t.Errorf("Unexpected auth header: %v", req.Header.Get("Authorization"))
+	}
+	if req.Header.Get("X-Text") != "Y29kZSAiMTIzNDU2Nzgi" {
+		t.Errorf("Unexpected base64 header: %v", req.Header.Get("X-Text"))
+	}
+
+	data := map[string]string{}
+	if err := json.Unmarshal(*body, &data); err != nil {
+		t.Fatalf("Body is not json: %s", *body)
+	}
+	if data["text"] != `code "12345678"` || data["from"] != "App" || data["lang"] != "en" {
+		t.Errorf("Unexpected body: %s", *body)
+	}
+}
+
+// Test template errors
+func TestGatewayRequest_Errors(t *testing.T) {
+	if _, err := newGatewayRequest(config.AppConfigGatewayRequest{URL: "{{.Msg.to"}); err == nil {
+		t.Error("Expected parse error")
+	}
+
+	request, _ := newGatewayRequest(config.AppConfigGatewayRequest{URL: "http://127.0.0.1/{{.Msg.phone}}"})
+	message := &SmsMessage{Envelope: Envelope{To: "+123121234567"}}
+	if _, err := request.send(config.AppConfigMessageGateway{}, message.templateValues()); !utiltaskqueue.IsPermanent(err) {
+		t.Errorf("Expected permanent error, got %v", err)
+	}
+
+	if request, _ := newGatewayRequest(config.AppConfigGatewayRequest{}); request != nil {
+		t.Error("Expected nil request for empty url")
+	}
+}
+
+// Test attachments and headers of email via form or json gateway
+func TestSendEmailVia_Extras(t *testing.T) {
+	server, _, body := captureServer(t, http.StatusOK)
+
+	message := &EmailMessage{
+		Envelope:    Envelope{To: "user@example.com"},
+		Subject:     "Invoice",
+		HTML:        "<p>invoice</p>",
+		Headers:     map[string]string{"X-Campaign": "billing"},
+		Attachments: []EmailAttachment{{Filename: "invoice.pdf", ContentType: "application/pdf", Content: []byte("%PDF")}},
+	}
+
+	// not sent without attachments, no retry
+	gw := &messageGateway{config: config.AppConfigMessageGateway{
+		HTTP: true, URL: server.URL, Body: `{"to":"","subject":"","html":""}`, BodyType: BodyTypeJSON,
+	}}
+	if _, err := sendEmailVia(gw, message); !utiltaskqueue.IsPermanent(err) {
+		t.Errorf("Expected permanent error for dropped attachments, got %v", err)
+	}
+
+	gw.config.Body = `{"to":"","subject":"","html":"","headers":"","attachments":""}`
+	if _, err := sendEmailVia(gw, message); err != nil {
+		t.Fatalf("sendEmailVia error: %v", err)
+	}
+
+	data := map[string]string{}
+	_ = json.Unmarshal(*body, &data)
+
+	attachments := []EmailAttachment{}
+	if err := json.Unmarshal([]byte(data["attachments"]), &attachments); err != nil ||
+		len(attachments) != 1 || string(attachments[0].Content) != "%PDF" {
+		t.Errorf("Unexpected attachments: %v", data["attachments"])
+	}
+	if data["headers"] != `{"X-Campaign":"billing"}` {
+		t.Errorf("Unexpected headers: %v", data["headers"])
+	}
+
+	// templated request is checked by placeholders of templates
+	gw.request, _ = newGatewayRequest(config.AppConfigGatewayRequest{URL: server.URL, Body: `{"to":{{json .Msg.to}}}`})
+	if _, err := sendEmailVia(gw, message); !utiltaskqueue.IsPermanent(err) {
+		t.Errorf("Expected permanent error for dropped extras of template, got %v", err)
+	}
+
+	gw.request, _ = newGatewayRequest(config.AppConfigGatewayRequest{URL: server.URL,
+		Headers: map[string]string{"X-Extras": `{{index .Msg "headers"}}`},
+		Body:    `{"to":{{json .Msg.to}}{{with $.Msg.attachments}},"attachments":{{.}}{{end}}}`,
+	})
+	if _, err := sendEmailVia(gw, message); err != nil {
+		t.Errorf("sendEmailVia error: %v", err)
+	}
+}
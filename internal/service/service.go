@@ -5,6 +5,7 @@ import (
 	"go-infra/internal/config"
 	"go-infra/internal/i18n"
 	"go-infra/internal/repository"
+	"go-infra/internal/util/utilredact"
 	"os"
 	"time"
 
@@ -22,12 +23,34 @@ type AppService interface {
 
 	Repository() repository.AppRepository
 
+	Channels() ChannelRegistry
 	SmsSender() SmsSender
 	EmailSender() EmailSender
+	ChatSender() ChatSender
+	Messages() MessageStore
+	Idempotency() IdempotencyStore
+	OTP() OTPService
+	Throttler() Throttler
+	Recipients() RecipientValidator
+	Templates() TemplateRegistry
+	Suppressions() SuppressionStore
+	Spend() SpendLedger
+	Sandbox() SandboxStore
 }
 type defaultAppService struct {
-	smsSender   SmsSender
-	emailSender EmailSender
+	channels     ChannelRegistry
+	smsSender    SmsSender
+	emailSender  EmailSender
+	chatSender   ChatSender
+	messages     MessageStore
+	idempotency  IdempotencyStore
+	otp          OTPService
+	throttler    Throttler
+	recipients   RecipientValidator
+	templates    TemplateRegistry
+	suppressions SuppressionStore
+	spend        SpendLedger
+	sandbox      SandboxStore
 
 	configSource *config.AppConfigSource
 	repository   repository.AppRepository
@@ -47,6 +70,20 @@ func (x *defaultAppService) mustConfig() {
 
 	mustConfigRuntime(appConfig)
 
+	mustConfigRedaction(appConfig)
+}
+
+// mustConfigRedaction mask sensitive data of all log output
+func mustConfigRedaction(appConfig *config.AppConfig) {
+
+	cfg := appConfig.Redaction
+
+	policy, err := utilredact.NewPolicy(cfg.Phone, cfg.Email, cfg.Patterns)
+	if err != nil {
+		panic(err)
+	}
+
+	xlog.SetRedactor(policy.Redact)
 }
 
 func (x *defaultAppService) mustBuild() {
@@ -57,12 +94,23 @@ func (x *defaultAppService) mustBuild() {
 
 	x.repository = repository.MustNewRepository(appConfig) // , appLogger)
 
-	x.smsSender = NewSmsSender(appConfig)
-	x.emailSender = NewEmailSender(appConfig)
-
 	if appConfig.DB.Migration {
-		mustCreateRepository(x) //
+		mustCreateRepository(x) // before senders, outbox resume
 	}
+
+	x.suppressions = NewSuppressionStore(appConfig, x.repository)
+	x.spend = NewSpendLedger(appConfig, x.repository)
+	x.sandbox = NewSandboxStore(appConfig)
+	x.channels = MustNewChannelRegistry(appConfig, x.repository, x.suppressions, x.spend, x.sandbox)
+	x.smsSender = mustMessageChannel[SmsMessage](x.channels, ChannelSms)
+	x.emailSender = mustMessageChannel[EmailMessage](x.channels, ChannelEmail)
+	x.chatSender = mustMessageChannel[ChatMessage](x.channels, ChannelChat)
+	x.messages = NewMessageStore(appConfig, x.repository)
+	x.idempotency = NewIdempotencyStore(appConfig, x.repository)
+	x.otp = NewOTPService(appConfig, x.repository)
+	x.throttler = NewThrottler(appConfig, x.repository)
+	x.recipients = NewRecipientValidator(appConfig)
+	x.templates = MustNewTemplateRegistry(appConfig)
 }
 
 func mustConfigRuntime(appConfig *config.AppConfig) {
@@ -121,8 +169,20 @@ func (x *defaultAppService) HasLang(code string) bool           { return x.lang.
 
 func (x *defaultAppService) Repository() repository.AppRepository { return x.repository }
 
-func (x *defaultAppService) SmsSender() SmsSender     { return x.smsSender }
-func (x *defaultAppService) EmailSender() EmailSender { return x.emailSender }
+func (x *defaultAppService) Channels() ChannelRegistry { return x.channels }
+func (x *defaultAppService) SmsSender() SmsSender      { return x.smsSender }
+func (x *defaultAppService) EmailSender() EmailSender  { return x.emailSender }
+func (x *defaultAppService) ChatSender() ChatSender    { return x.chatSender }
+func (x *defaultAppService) Messages() MessageStore    { return x.messages }
+
+func (x *defaultAppService) Idempotency() IdempotencyStore  { return x.idempotency }
+func (x *defaultAppService) OTP() OTPService                { return x.otp }
+func (x *defaultAppService) Throttler() Throttler           { return x.throttler }
+func (x *defaultAppService) Recipients() RecipientValidator { return x.recipients }
+func (x *defaultAppService) Templates() TemplateRegistry    { return x.templates }
+func (x *defaultAppService) Suppressions() SuppressionStore { return x.suppressions }
+func (x *defaultAppService) Spend() SpendLedger             { return x.spend }
+func (x *defaultAppService) Sandbox() SandboxStore          { return x.sandbox }
 
 func BasicAuth(username, password string) string {
 	// Combine username and password in the format "username:password"
@@ -3,38 +3,26 @@ package service
 import (
 	"fmt"
 	"go-infra/internal/config"
-	xlog "go-infra/internal/util/utillog"
+	"go-infra/internal/util/utilredact"
 	"go-infra/internal/util/utiltaskqueue"
-	"time"
 )
 
 type SmsMessage struct {
-	From      string
-	To        string
-	Lang      string
-	Text      string
-	CreatedAt time.Time
-	MaxAge    int16 // seconds, expires after createdAt+MaxAge if MaxAge>0
+	Envelope
+	Text string
 }
 
-type SmsSender interface {
-	Send(message SmsMessage) error
+// Redacted copy with secrets masked
+func (message SmsMessage) Redacted() SmsMessage {
+	message.Text = utilredact.Secrets(message.Text, message.Secrets...)
+	message.Secrets = nil
+	return message
 }
 
-type smsSender struct {
-	Debug     bool
-	taskQueue *utiltaskqueue.TaskQueue[SmsMessage]
-}
-
-func (x *smsSender) Send(message SmsMessage) error {
-
-	return x.taskQueue.Enqueue(&message)
-
-}
+func (message *SmsMessage) prepare() {}
 
-type smsTaskQueue struct {
-	Debug   bool
-	gateway config.AppConfigMessageGateway
+func (message *SmsMessage) summary() string {
+	return fmt.Sprintf("message: `%v`", utilredact.Secrets(message.Text, message.Secrets...))
 }
 
 func (message *SmsMessage) exctractValueForSms(name string) (string, error) {
@@ -49,47 +37,50 @@ func (message *SmsMessage) exctractValueForSms(name string) (string, error) {
 	return "", fmt.Errorf("prop not exists: %s", name)
 }
 
-func (x smsTaskQueue) handlerSms(smsMessage *SmsMessage) error {
-
-	gw := x.gateway
+// templateValues message fields for gateway request template
+func (message *SmsMessage) templateValues() map[string]string {
+	return map[string]string{
+		"id":   message.ID,
+		"from": message.From,
+		"to":   message.To,
+		"lang": message.Lang,
+		"text": message.Text,
+	}
+}
 
-	smsMessage.From = gw.From
+// sendSmsVia send via gateway, provider message id returned
+func sendSmsVia(gateway *messageGateway, smsMessage *SmsMessage) (string, error) {
 
-	if x.Debug || gw.Stdout {
-		xlog.Info("to: `%v` message: `%v`", smsMessage.To, smsMessage.Text)
-	}
+	gw := gateway.config
 
 	if gw.HTTP {
+
+		if gateway.request != nil {
+			respBody, err := gateway.request.send(gw, smsMessage.templateValues())
+			return responseID(gw, respBody), err
+		}
+
 		sd := newDataSender()
 
 		err := sd.fillQuery(gw, smsMessage.exctractValueForSms)
 
 		if err != nil {
-			return err
+			return "", utiltaskqueue.Permanent(err)
 		}
 		err = sd.fillBody(gw, smsMessage.exctractValueForSms)
 
 		if err != nil {
-			return err
+			return "", utiltaskqueue.Permanent(err)
 		}
 
-		return sd.sendData(gw)
+		respBody, err := sd.sendData(gw)
+		return responseID(gw, respBody), err
 
 	}
-	return nil
+	return "", nil
 }
 
-func NewSmsSender(appConfig *config.AppConfig) SmsSender {
-
-	tq := smsTaskQueue{
-
-		Debug:   appConfig.Debug,
-		gateway: appConfig.SmsGateway,
-	}
-
-	return &smsSender{
-		Debug:     appConfig.Debug,
-		taskQueue: utiltaskqueue.NewTaskQueue("sms sender", tq.handlerSms, 1),
-	}
-
+// newSmsChannel channel of sms type
+func newSmsChannel(appConfig *config.AppConfig, cfg config.AppConfigChannel, deps channelDeps) (Channel, error) {
+	return newMessageChannel(appConfig, cfg, deps, sendSmsVia)
 }
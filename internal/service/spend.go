@@ -0,0 +1,335 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-infra/internal/config"
+	"go-infra/internal/repository"
+	"go-infra/internal/util/utiltaskqueue"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// spend periods, UTC
+const (
+	spendDayLayout   = "2006-01-02"
+	spendMonthLayout = "2006-01"
+)
+
+// GatewaySpend spend of gateway in day or month period
+type GatewaySpend struct {
+	Channel   string `gorm:"primaryKey;size:32"`
+	Gateway   string `gorm:"primaryKey;size:64"`
+	Period    string `gorm:"primaryKey;size:10"` // 2026-10-18 or 2026-10
+	Amount    float64
+	Messages  int64
+	UpdatedAt time.Time
+}
+
+// SpendCapError spend cap of gateway is reached, message is refused
+type SpendCapError struct {
+	Gateway string
+	Period  string // daily, monthly
+	Cap     float64
+}
+
+func (e *SpendCapError) Error() string {
+	return fmt.Sprintf("gateway %v %v spend cap %v reached", e.Gateway, e.Period, e.Cap)
+}
+
+// GatewaySpendReport current spend of gateway
+type GatewaySpendReport struct {
+	Channel         string  `json:"channel"`
+	Gateway         string  `json:"gateway"`
+	Day             string  `json:"day"`
+	DailySpend      float64 `json:"daily_spend"`
+	DailyMessages   int64   `json:"daily_messages"`
+	DailyCap        float64 `json:"daily_cap,omitempty"`
+	Month           string  `json:"month"`
+	MonthlySpend    float64 `json:"monthly_spend"`
+	MonthlyMessages int64   `json:"monthly_messages"`
+	MonthlyCap      float64 `json:"monthly_cap,omitempty"`
+}
+
+// SpendLedger cost accounting of gateways, persisted in repository
+type SpendLedger interface {
+	// Report spend of all gateways in current day and month
+	Report() ([]GatewaySpendReport, error)
+
+	available(channel string, gateways []*messageGateway, to string) ([]*messageGateway, error)
+	reserve(channel string, gw *messageGateway, to string) ([]string, error)
+	commit(channel string, gw *messageGateway, to string)
+	refund(channel string, gw *messageGateway, to string, periods []string) error
+}
+
+type spendLedger struct {
+	appConfig  *config.AppConfig
+	repository repository.AppRepository
+	now        func() time.Time
+}
+
+// NewSpendLedger new ledger in repository
+func NewSpendLedger(appConfig *config.AppConfig, repo repository.AppRepository) SpendLedger {
+	return &spendLedger{appConfig: appConfig, repository: repo, now: time.Now}
+}
+
+// gatewayPrice price of message to recipient, price of longest matched phone prefix or default price
+func gatewayPrice(cost config.AppConfigGatewayCost, to string) float64 {
+
+	res := cost.Price
+	matched := -1
+	phone := normalizePhonePrefix(to)
+
+	for prefix, price := range cost.Prices {
+		v := normalizePhonePrefix(prefix)
+		if len(v) > matched && strings.HasPrefix(phone, v) {
+			res, matched = price, len(v)
+		}
+	}
+
+	return res
+}
+
+// spendPeriods day and month periods of time
+func spendPeriods(now time.Time) (string, string) {
+	now = now.UTC()
+	return now.Format(spendDayLayout), now.Format(spendMonthLayout)
+}
+
+// spendCapReached *SpendCapError if message price exceeds cap of day or month
+func spendCapReached(gw config.AppConfigMessageGateway, price float64, daily float64, monthly float64) error {
+
+	cost := gw.Cost
+
+	if cost.DailyCap > 0 && daily+price > cost.DailyCap {
+		return &SpendCapError{Gateway: gw.Name, Period: "daily", Cap: cost.DailyCap}
+	}
+
+	if cost.MonthlyCap > 0 && monthly+price > cost.MonthlyCap {
+		return &SpendCapError{Gateway: gw.Name, Period: "monthly", Cap: cost.MonthlyCap}
+	}
+
+	return nil
+}
+
+// spent amounts of gateway by period
+func (x *spendLedger) spent(channel string, gateway string, periods ...string) (map[string]GatewaySpend, error) {
+
+	rows := []GatewaySpend{}
+
+	err := x.repository.Where("channel = ? AND gateway = ? AND period IN ?", channel, gateway, periods).Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]GatewaySpend{}
+	for _, row := range rows {
+		res[row.Period] = row
+	}
+
+	return res, nil
+}
+
+// available gateways not capped for message to recipient in route order, checked again by reserve,
+// cheaper first if primary gateway is capped, *SpendCapError of primary if all are capped
+func (x *spendLedger) available(channel string, gateways []*messageGateway, to string) ([]*messageGateway, error) {
+
+	day, month := spendPeriods(x.now())
+
+	res := make([]*messageGateway, 0, len(gateways))
+	var capErr error
+
+	for _, gw := range gateways {
+
+		cost := gw.config.Cost
+
+		if cost.DailyCap > 0 || cost.MonthlyCap > 0 {
+
+			spent, err := x.spent(channel, gw.name(), day, month)
+			if err != nil {
+				return nil, err
+			}
+
+			err = spendCapReached(gw.config, gatewayPrice(cost, to), spent[day].Amount, spent[month].Amount)
+			if err != nil {
+				metricGatewayCapped.WithLabelValues(channel, gw.name()).Inc()
+				if capErr == nil {
+					capErr = err
+				}
+				continue
+			}
+		}
+
+		res = append(res, gw)
+	}
+
+	if len(res) == 0 && capErr != nil {
+		return nil, utiltaskqueue.Permanent(capErr)
+	}
+
+	if len(res) > 0 && len(gateways) > 0 && res[0] != gateways[0] {
+		slices.SortStableFunc(res, func(a, b *messageGateway) int {
+			pa, pb := gatewayPrice(a.config.Cost, to), gatewayPrice(b.config.Cost, to)
+			switch {
+			case pa < pb:
+				return -1
+			case pa > pb:
+				return 1
+			}
+			return 0
+		})
+	}
+
+	return res, nil
+}
+
+// reserve add price of message to day and month spend of gateway before send, charged periods returned,
+// nothing is written for free gateway without caps,
+// permanent *SpendCapError if cap of period is reached, amount is checked and added by one statement
+func (x *spendLedger) reserve(channel string, gw *messageGateway, to string) ([]string, error) {
+
+	cost := gw.config.Cost
+	price := gatewayPrice(cost, to)
+
+	if price == 0 && cost.DailyCap <= 0 && cost.MonthlyCap <= 0 {
+		return nil, nil
+	}
+
+	day, month := spendPeriods(x.now())
+
+	periods := []struct {
+		period string
+		name   string
+		cap    float64
+	}{
+		{day, "daily", cost.DailyCap},
+		{month, "monthly", cost.MonthlyCap},
+	}
+
+	err := x.repository.Transaction(func(tx repository.AppRepository) error {
+
+		for _, itm := range periods {
+
+			applied, err := x.add(tx, channel, gw.name(), itm.period, price, itm.cap)
+			if err != nil {
+				return err
+			}
+			if !applied {
+				return &SpendCapError{Gateway: gw.name(), Period: itm.name, Cap: itm.cap}
+			}
+		}
+
+		return nil
+	})
+
+	var capErr *SpendCapError
+	if errors.As(err, &capErr) {
+		metricGatewayCapped.WithLabelValues(channel, gw.name()).Inc()
+		return nil, utiltaskqueue.Permanent(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{day, month}, nil
+}
+
+// commit count reserved price of sent message in metric
+func (x *spendLedger) commit(channel string, gw *messageGateway, to string) {
+
+	if price := gatewayPrice(gw.config.Cost, to); price > 0 {
+		metricGatewaySpend.WithLabelValues(channel, gw.name()).Add(price)
+	}
+}
+
+// refund remove reserved price of message not sent from periods charged by reserve,
+// not current ones, send may pass midnight
+func (x *spendLedger) refund(channel string, gw *messageGateway, to string, periods []string) error {
+
+	if len(periods) == 0 {
+		return nil
+	}
+
+	price := gatewayPrice(gw.config.Cost, to)
+
+	return x.repository.Model(&GatewaySpend{}).
+		Where("channel = ? AND gateway = ? AND period IN ?", channel, gw.name(), periods).
+		Updates(map[string]any{
+			"amount":   gorm.Expr("amount - ?", price),
+			"messages": gorm.Expr("messages - 1"),
+		}).Error
+}
+
+// add upsert price of one message to period, not applied if cap > 0 and amount would exceed it
+func (x *spendLedger) add(tx repository.AppRepository, channel string, gateway string, period string,
+	price float64, cap float64,
+) (bool, error) {
+
+	if cap > 0 && price > cap {
+		return false, nil
+	}
+
+	onConflict := clause.OnConflict{
+		Columns: []clause.Column{{Name: "channel"}, {Name: "gateway"}, {Name: "period"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"amount":     gorm.Expr("gateway_spends.amount + excluded.amount"),
+			"messages":   gorm.Expr("gateway_spends.messages + excluded.messages"),
+			"updated_at": gorm.Expr("excluded.updated_at"),
+		}),
+	}
+
+	if cap > 0 {
+		// row is locked by upsert, concurrent senders wait and see added amount
+		onConflict.Where = clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "gateway_spends.amount + excluded.amount <= ?", Vars: []any{cap}},
+		}}
+	}
+
+	row := &GatewaySpend{Channel: channel, Gateway: gateway, Period: period, Amount: price, Messages: 1}
+
+	res := tx.Driver().Clauses(onConflict).Create(row)
+
+	return res.RowsAffected > 0, res.Error
+}
+
+// Report spend of all gateways of channels in current day and month
+func (x *spendLedger) Report() ([]GatewaySpendReport, error) {
+
+	day, month := spendPeriods(x.now())
+	res := []GatewaySpendReport{}
+
+	for _, cfg := range channelConfigs(x.appConfig) {
+
+		// channels without gateways, chat, have no cost
+		for _, gw := range cfg.Gateways {
+
+			if gw.Name == "" {
+				gw.Name = DefaultGatewayName
+			}
+
+			spent, err := x.spent(cfg.Name, gw.Name, day, month)
+			if err != nil {
+				return nil, err
+			}
+
+			res = append(res, GatewaySpendReport{
+				Channel:         cfg.Name,
+				Gateway:         gw.Name,
+				Day:             day,
+				DailySpend:      spent[day].Amount,
+				DailyMessages:   spent[day].Messages,
+				DailyCap:        gw.Cost.DailyCap,
+				Month:           month,
+				MonthlySpend:    spent[month].Amount,
+				MonthlyMessages: spent[month].Messages,
+				MonthlyCap:      gw.Cost.MonthlyCap,
+			})
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"errors"
+	"go-infra/internal/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test price by longest phone prefix
+func TestGatewayPrice(t *testing.T) {
+	cost := config.AppConfigGatewayCost{Price: 0.05, Prices: map[string]float64{"+44": 0.04, "+447": 0.03, "+234": 0.5}}
+
+	cases := map[string]float64{
+		"+447700900123":    0.03,
+		"+442079460000":    0.04,
+		"+2348012345678":   0.5,
+		"+12025550100":     0.05,
+		"user@example.com": 0.05,
+	}
+
+	for to, expected := range cases {
+		if res := gatewayPrice(cost, to); res != expected {
+			t.Errorf("gatewayPrice(%q): expected %v, got %v", to, expected, res)
+		}
+	}
+}
+
+// Test daily and monthly caps
+func TestSpendCapReached(t *testing.T) {
+	gw := config.AppConfigMessageGateway{Name: "a", Cost: config.AppConfigGatewayCost{DailyCap: 10, MonthlyCap: 100}}
+
+	if err := spendCapReached(gw, 1, 9, 50); err != nil {
+		t.Errorf("Unexpected %v", err)
+	}
+
+	var capErr *SpendCapError
+	if err := spendCapReached(gw, 1, 9.5, 50); !errors.As(err, &capErr) || capErr.Period != "daily" {
+		t.Errorf("Expected daily cap, got %v", err)
+	}
+	if err := spendCapReached(gw, 1, 0, 99.5); !errors.As(err, &capErr) || capErr.Period != "monthly" {
+		t.Errorf("Expected monthly cap, got %v", err)
+	}
+	if err := spendCapReached(config.AppConfigMessageGateway{}, 1, 1e6, 1e6); err != nil {
+		t.Errorf("Expected no cap, got %v", err)
+	}
+}
+
+// Test periods are UTC
+func TestSpendPeriods(t *testing.T) {
+	now := time.Date(2026, 10, 31, 23, 30, 0, 0, time.FixedZone("", -2*3600))
+
+	if day, month := spendPeriods(now); day != "2026-11-01" || month != "2026-11" {
+		t.Errorf("Unexpected periods %v %v", day, month)
+	}
+}
+
+// Test gateways without caps are not checked
+func TestSpendLedger_AvailableNoCaps(t *testing.T) {
+	ledger := &spendLedger{now: time.Now}
+	gateways := []*messageGateway{{config: config.AppConfigMessageGateway{Name: "a"}}, {config: config.AppConfigMessageGateway{Name: "b"}}}
+
+	res, err := ledger.available(ChannelSms, gateways, "+447700900123")
+	if err != nil || strings.Join(gatewayNames(res), ",") != "a,b" {
+		t.Errorf("Unexpected %v, %v", gatewayNames(res), err)
+	}
+}
+
+// Test price above cap is refused before upsert
+func TestSpendLedger_AddAboveCap(t *testing.T) {
+	ledger := &spendLedger{now: time.Now}
+
+	if applied, err := ledger.add(nil, ChannelSms, "a", "2026-10", 5, 1); applied || err != nil {
+		t.Errorf("Expected not applied, got %v %v", applied, err)
+	}
+}
+
+// Test free gateway without caps is not written to ledger, nothing to refund
+func TestSpendLedger_ReserveFree(t *testing.T) {
+	ledger := &spendLedger{now: time.Now}
+	gw := &messageGateway{config: config.AppConfigMessageGateway{Name: "a"}}
+
+	periods, err := ledger.reserve(ChannelSms, gw, "+447700900123")
+	if len(periods) != 0 || err != nil {
+		t.Errorf("Expected no periods charged, got %v %v", periods, err)
+	}
+
+	if err := ledger.refund(ChannelSms, gw, "+447700900123", periods); err != nil {
+		t.Errorf("Unexpected %v", err)
+	}
+}
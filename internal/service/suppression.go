@@ -0,0 +1,218 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-infra/internal/config"
+	"go-infra/internal/repository"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// suppression reasons
+const (
+	SuppressionOptOut     = "opt_out"
+	SuppressionHardBounce = "hard_bounce"
+	SuppressionAbuse      = "abuse"
+	SuppressionManual     = "manual"
+)
+
+// suppressionBatchSize rows per insert of import
+const suppressionBatchSize = 500
+
+// ErrSuppressionNotFound recipient is not suppressed
+var ErrSuppressionNotFound = errors.New("suppression not found")
+
+// ErrSuppressionInvalid channel, recipient or reason of suppression is not valid
+var ErrSuppressionInvalid = errors.New("suppression is not valid")
+
+// Suppression recipient not to be messaged by channel type, permanent if ExpiresAt is nil
+type Suppression struct {
+	Channel   string     `gorm:"primaryKey;size:32" json:"channel"`
+	Recipient string     `gorm:"primaryKey;size:320" json:"recipient"`
+	Reason    string     `gorm:"size:32" json:"reason"`
+	Source    string     `gorm:"size:128" json:"source,omitempty"` // api, import, crm
+	ExpiresAt *time.Time `gorm:"index" json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// SuppressedError recipient is suppressed, message is not accepted
+type SuppressedError struct {
+	Channel   string
+	Recipient string
+	Reason    string
+}
+
+func (e *SuppressedError) Error() string {
+	return fmt.Sprintf("recipient is suppressed: %v", e.Reason)
+}
+
+// SuppressionStore recipients opted out, bounced or flagged, checked by senders before enqueue
+type SuppressionStore interface {
+	// Check *SuppressedError for first active suppression of recipients
+	Check(channel string, recipients []string) error
+	Get(channel string, recipient string) (*Suppression, error)
+	// List active suppressions, channel and reason are optional filters
+	List(channel string, reason string, limit int, offset int) ([]Suppression, error)
+	// Put add or replace suppression
+	Put(item Suppression) error
+	// Import add or replace suppressions by one transaction
+	Import(items []Suppression) error
+	Delete(channel string, recipient string) error
+}
+
+type suppressionStore struct {
+	repository repository.AppRepository
+}
+
+// NewSuppressionStore new store in repository
+func NewSuppressionStore(_ *config.AppConfig, repo repository.AppRepository) SuppressionStore {
+	return &suppressionStore{repository: repo}
+}
+
+// CheckSuppression channel type, recipient and reason are valid
+func CheckSuppression(item Suppression) error {
+
+	if !slices.Contains([]string{ChannelSms, ChannelEmail, ChannelChat}, item.Channel) {
+		return fmt.Errorf("%w: channel not supported: %v", ErrSuppressionInvalid, item.Channel)
+	}
+
+	if item.Recipient == "" {
+		return fmt.Errorf("%w: recipient is empty", ErrSuppressionInvalid)
+	}
+
+	if !slices.Contains([]string{SuppressionOptOut, SuppressionHardBounce, SuppressionAbuse, SuppressionManual}, item.Reason) {
+		return fmt.Errorf("%w: reason not supported: %v", ErrSuppressionInvalid, item.Reason)
+	}
+
+	return nil
+}
+
+// active suppressions not expired at now
+func (x *suppressionStore) active(now time.Time) *gorm.DB {
+	return x.repository.Where("(expires_at IS NULL OR expires_at > ?)", now)
+}
+
+// Check *SuppressedError for first active suppression of recipients
+func (x *suppressionStore) Check(channel string, recipients []string) error {
+
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	row := Suppression{}
+
+	err := x.active(time.Now()).Where("channel = ? AND recipient IN ?", channel, recipients).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return &SuppressedError{Channel: row.Channel, Recipient: row.Recipient, Reason: row.Reason}
+}
+
+// Get active suppression of recipient
+func (x *suppressionStore) Get(channel string, recipient string) (*Suppression, error) {
+
+	row := Suppression{}
+
+	err := x.active(time.Now()).Where("channel = ? AND recipient = ?", channel, recipient).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSuppressionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &row, nil
+}
+
+// List active suppressions, latest first
+func (x *suppressionStore) List(channel string, reason string, limit int, offset int) ([]Suppression, error) {
+
+	query := x.active(time.Now())
+
+	if channel != "" {
+		query = query.Where("channel = ?", channel)
+	}
+	if reason != "" {
+		query = query.Where("reason = ?", reason)
+	}
+
+	res := []Suppression{}
+
+	err := query.Order("updated_at DESC").Limit(limit).Offset(offset).Find(&res).Error
+
+	return res, err
+}
+
+// upsert add or replace by channel and recipient
+func upsertSuppressions() clause.OnConflict {
+	return clause.OnConflict{
+		Columns:   []clause.Column{{Name: "channel"}, {Name: "recipient"}},
+		DoUpdates: clause.AssignmentColumns([]string{"reason", "source", "expires_at", "updated_at"}),
+	}
+}
+
+// Put add or replace suppression
+func (x *suppressionStore) Put(item Suppression) error {
+
+	if err := CheckSuppression(item); err != nil {
+		return err
+	}
+
+	return x.repository.Driver().Clauses(upsertSuppressions()).Create(&item).Error
+}
+
+// Import add or replace suppressions by one transaction, none is written if any is not valid,
+// last one of same recipient wins
+func (x *suppressionStore) Import(items []Suppression) error {
+
+	index := map[[2]string]int{}
+	list := make([]Suppression, 0, len(items))
+
+	for _, itm := range items {
+
+		if err := CheckSuppression(itm); err != nil {
+			return err
+		}
+
+		key := [2]string{itm.Channel, itm.Recipient}
+		if i, ok := index[key]; ok {
+			list[i] = itm // one row per upsert statement
+			continue
+		}
+
+		index[key] = len(list)
+		list = append(list, itm)
+	}
+
+	if len(list) == 0 {
+		return nil
+	}
+
+	return x.repository.Transaction(func(tx repository.AppRepository) error {
+		return tx.Driver().Clauses(upsertSuppressions()).CreateInBatches(list, suppressionBatchSize).Error
+	})
+}
+
+// Delete suppression of recipient, expired one included
+func (x *suppressionStore) Delete(channel string, recipient string) error {
+
+	tx := x.repository.Where("channel = ? AND recipient = ?", channel, recipient).Delete(&Suppression{})
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	if tx.RowsAffected == 0 {
+		return ErrSuppressionNotFound
+	}
+
+	return nil
+}
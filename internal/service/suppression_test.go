@@ -0,0 +1,30 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+// Test channel type, recipient and reason of suppression
+func TestCheckSuppression(t *testing.T) {
+	cases := []struct {
+		item  Suppression
+		valid bool
+	}{
+		{Suppression{Channel: ChannelSms, Recipient: "+447700900123", Reason: SuppressionOptOut}, true},
+		{Suppression{Channel: ChannelEmail, Recipient: "user@example.com", Reason: SuppressionHardBounce, Source: "crm"}, true},
+		{Suppression{Channel: "sms-bulk", Recipient: "+447700900123", Reason: SuppressionOptOut}, false},
+		{Suppression{Channel: ChannelSms, Reason: SuppressionAbuse}, false},
+		{Suppression{Channel: ChannelSms, Recipient: "+447700900123", Reason: "spam"}, false},
+	}
+
+	for i, itm := range cases {
+		err := CheckSuppression(itm.item)
+		if itm.valid && err != nil {
+			t.Errorf("Case %d: unexpected %v", i, err)
+		}
+		if !itm.valid && !errors.Is(err, ErrSuppressionInvalid) {
+			t.Errorf("Case %d: expected %v, got %v", i, ErrSuppressionInvalid, err)
+		}
+	}
+}
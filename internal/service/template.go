@@ -0,0 +1,269 @@
+package service
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"go-infra/internal/config"
+	"go-infra/internal/i18n"
+	"go-infra/internal/util/utilconfig"
+	xlog "go-infra/internal/util/utillog"
+	htmltemplate "html/template"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed template/*
+var fsTemplate embed.FS
+
+// TemplateEmailPasscode name of passcode email template
+const TemplateEmailPasscode = "passcode"
+
+// templateSubject optional subject template defined in email template, {{define "subject"}}...{{end}}
+const templateSubject = "subject"
+
+// template file extension by channel, email is html/template, sms is text/template
+var templateExt = map[string]string{
+	ChannelEmail: ".html",
+	ChannelSms:   ".txt",
+}
+
+// ErrTemplateNotFound template name not exists
+var ErrTemplateNotFound = errors.New("template not found")
+
+// EmailPasscodeTemplateData data of passcode email template
+type EmailPasscodeTemplateData struct {
+	LangCode      string
+	AppTitle      string
+	LabelPasscode string
+	Passcode      string
+	Subject       string
+}
+
+// TemplateMessageData data of named templates, {{.Data.name}}, {{.T "Hello, {0}" .Data.name}}
+type TemplateMessageData struct {
+	LangCode string
+	AppTitle string
+	Data     map[string]any
+	userLang i18n.UserLang
+}
+
+// NewTemplateMessageData data with translations of user lang
+func NewTemplateMessageData(userLang i18n.UserLang, appTitle string, data map[string]any) TemplateMessageData {
+	return TemplateMessageData{
+		LangCode: userLang.LangCode(),
+		AppTitle: appTitle,
+		Data:     data,
+		userLang: userLang,
+	}
+}
+
+// T translate text to user lang
+func (x TemplateMessageData) T(text string, args ...any) string {
+	if x.userLang == nil {
+		return text
+	}
+	return x.userLang.Lang(text, args...)
+}
+
+// RenderedTemplate rendered message, subject if template defines it
+type RenderedTemplate struct {
+	Subject string
+	Body    string
+}
+
+// TemplateRegistry templates by channel, name and lang
+type TemplateRegistry interface {
+	// Render template of lang, default lang or embedded one
+	Render(channel string, name string, lang string, data any) (RenderedTemplate, error)
+	// Names template names of channel
+	Names(channel string) []string
+}
+
+// messageTemplate html or text template
+type messageTemplate interface {
+	Execute(w io.Writer, data any) error
+	ExecuteTemplate(w io.Writer, name string, data any) error
+}
+
+type templateRegistry struct {
+	defaultLang string
+	templates   map[string]map[string]messageTemplate // <channel>_<name>, lang, embedded by empty lang
+}
+
+// MustNewTemplateRegistry load embedded templates and <channel>_<name>.<lang>.<ext> overrides from config path
+func MustNewTemplateRegistry(appConfig *config.AppConfig) TemplateRegistry {
+
+	res := &templateRegistry{templates: map[string]map[string]messageTemplate{}}
+
+	if len(appConfig.Lang.Langs) > 0 {
+		res.defaultLang = appConfig.Lang.Langs[0]
+	}
+
+	for channel, names := range map[string][]string{
+		ChannelEmail: appConfig.Messenger.Templates,
+		ChannelSms:   appConfig.Messenger.SmsTemplates,
+	} {
+		res.mustLoad(appConfig, channel, names)
+	}
+
+	return res
+}
+
+func (x *templateRegistry) mustLoad(appConfig *config.AppConfig, channel string, names []string) {
+
+	ext := templateExt[channel]
+
+	files, err := fs.Glob(fsTemplate, "template/"+channel+"_*"+ext)
+	if err != nil {
+		panic(err)
+	}
+
+	names = slices.Clone(names)
+
+	for _, file := range files {
+
+		name := strings.TrimSuffix(strings.TrimPrefix(path.Base(file), channel+"_"), ext)
+
+		data, err := fsTemplate.ReadFile(file)
+		if err != nil {
+			panic(err)
+		}
+
+		x.mustAdd(channel, name, "", file, string(data))
+
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	// later path overrides, same as config files
+	for _, name := range names {
+		for _, lang := range appConfig.Lang.Langs {
+			for _, dir := range appConfig.ConfigPath {
+
+				fileName := fmt.Sprintf("%v_%v.%v%v", channel, name, lang, ext)
+
+				data, ok, err := utilconfig.LoadText(dir, fileName)
+				if err != nil {
+					panic(err)
+				}
+				if !ok {
+					continue
+				}
+
+				xlog.Info("loading template from: %v %v", dir, fileName)
+
+				x.mustAdd(channel, name, lang, fileName, data)
+			}
+		}
+	}
+
+	for _, name := range names {
+		key := channel + "_" + name
+		if len(x.templates[key]) == 0 {
+			panic(fmt.Errorf("error template not found in config path: %v.<lang>%v", key, ext))
+		}
+		x.mustValidate(key, templateSample(channel, name))
+	}
+}
+
+func (x *templateRegistry) mustAdd(channel string, name string, lang string, file string, data string) {
+
+	var tmpl messageTemplate
+	var err error
+
+	if channel == ChannelEmail {
+		tmpl, err = htmltemplate.New(name).Parse(data)
+	} else {
+		tmpl, err = texttemplate.New(name).Parse(data)
+	}
+
+	if err != nil {
+		panic(fmt.Errorf("error template %v: %v", file, err))
+	}
+
+	key := channel + "_" + name
+
+	if x.templates[key] == nil {
+		x.templates[key] = map[string]messageTemplate{}
+	}
+
+	x.templates[key][lang] = tmpl
+}
+
+// templateSample data to validate template on startup
+func templateSample(channel string, name string) any {
+	if channel == ChannelEmail && name == TemplateEmailPasscode {
+		return EmailPasscodeTemplateData{}
+	}
+	return TemplateMessageData{}
+}
+
+// mustValidate execute all variants of template with sample data, panic on error
+func (x *templateRegistry) mustValidate(key string, sample any) {
+
+	for lang, tmpl := range x.templates[key] {
+		if _, err := execTemplate(tmpl, sample); err != nil {
+			panic(fmt.Errorf("error template %v lang %q: %v", key, lang, err))
+		}
+	}
+}
+
+// Render template of lang, fallback to default lang and embedded one
+func (x *templateRegistry) Render(channel string, name string, lang string, data any) (RenderedTemplate, error) {
+
+	langs := x.templates[channel+"_"+name]
+	if langs == nil {
+		return RenderedTemplate{}, fmt.Errorf("%w: %v %v", ErrTemplateNotFound, channel, name)
+	}
+
+	for _, code := range []string{lang, x.defaultLang, ""} {
+		if tmpl, ok := langs[code]; ok {
+			return execTemplate(tmpl, data)
+		}
+	}
+
+	return RenderedTemplate{}, fmt.Errorf("%w: %v %v lang %v", ErrTemplateNotFound, channel, name, lang)
+}
+
+func execTemplate(tmpl messageTemplate, data any) (RenderedTemplate, error) {
+
+	res := RenderedTemplate{}
+
+	bu := strings.Builder{}
+	if err := tmpl.Execute(&bu, data); err != nil {
+		return res, err
+	}
+	res.Body = bu.String()
+
+	if lookup, ok := tmpl.(interface{ DefinedTemplates() string }); ok &&
+		strings.Contains(lookup.DefinedTemplates(), `"`+templateSubject+`"`) {
+
+		bu.Reset()
+		if err := tmpl.ExecuteTemplate(&bu, templateSubject, data); err != nil {
+			return res, err
+		}
+		res.Subject = strings.TrimSpace(bu.String())
+	}
+
+	return res, nil
+}
+
+// Names template names of channel
+func (x *templateRegistry) Names(channel string) []string {
+
+	res := []string{}
+	for key := range x.templates {
+		if name, ok := strings.CutPrefix(key, channel+"_"); ok {
+			res = append(res, name)
+		}
+	}
+	slices.Sort(res)
+
+	return res
+}
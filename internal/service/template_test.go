@@ -0,0 +1,74 @@
+package service
+
+import (
+	"go-infra/internal/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test lang override from config path, fallback to default lang and embedded template
+func TestTemplateRegistry_Render(t *testing.T) {
+	base, override := t.TempDir(), t.TempDir()
+
+	write := func(dir, name, data string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(base, "email_passcode.es.html", `base es {{.Passcode}}`)
+	write(override, "email_passcode.es.html", `override es {{.Passcode}}`)
+	write(override, "sms_welcome.en.txt", `{{.T "Hi"}} {{.Data.name}} <3`)
+	write(override, "email_welcome.en.html", `{{define "subject"}}{{.T "Welcome"}}{{end}}welcome {{.Data.name}}`)
+
+	appConfig := &config.AppConfig{}
+	appConfig.Lang.Langs = []string{"en", "es", "de"}
+	appConfig.ConfigPath = []string{base, override}
+	appConfig.Messenger.Templates = []string{"welcome"}
+	appConfig.Messenger.SmsTemplates = []string{"welcome"}
+
+	x := MustNewTemplateRegistry(appConfig)
+
+	data := EmailPasscodeTemplateData{Passcode: "1234"}
+
+	if res, _ := x.Render(ChannelEmail, TemplateEmailPasscode, "es", data); res.Body != "override es 1234" {
+		t.Errorf("Expected later config path override, got %q", res)
+	}
+
+	if res, _ := x.Render(ChannelEmail, TemplateEmailPasscode, "de", data); !strings.Contains(res.Body, "<b>1234</b>") {
+		t.Errorf("Expected embedded template, got %q", res)
+	}
+
+	res, err := x.Render(ChannelEmail, "welcome", "de", TemplateMessageData{Data: map[string]any{"name": "Ann"}})
+	if err != nil || res.Body != "welcome Ann" || res.Subject != "Welcome" {
+		t.Errorf("Expected default lang template, got %+v %v", res, err)
+	}
+
+	res, _ = x.Render(ChannelSms, "welcome", "en", TemplateMessageData{Data: map[string]any{"name": "Ann"}})
+	if res.Body != "Hi Ann <3" {
+		t.Errorf("Expected not escaped sms text, got %q", res.Body)
+	}
+
+	if _, err := x.Render(ChannelEmail, "missing", "en", nil); err == nil {
+		t.Error("Expected not found error")
+	}
+}
+
+// Test broken template fails on startup
+func TestTemplateRegistry_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "email_passcode.en.html"), []byte(`{{.Unknown}}`), 0o600)
+
+	appConfig := &config.AppConfig{}
+	appConfig.Lang.Langs = []string{"en"}
+	appConfig.ConfigPath = []string{dir}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic")
+		}
+	}()
+
+	MustNewTemplateRegistry(appConfig)
+}
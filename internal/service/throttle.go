@@ -0,0 +1,342 @@
+package service
+
+import (
+	"fmt"
+	"go-infra/internal/config"
+	"go-infra/internal/repository"
+	"go-infra/internal/util/utilphone"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// throttle rule keys
+const (
+	ThrottleKeyRecipient = "recipient"
+	ThrottleKeyIP        = "ip"
+	ThrottleKeyCountry   = "country"
+)
+
+// throttle stores
+const (
+	ThrottleStoreMemory = "memory"
+	ThrottleStoreDB     = "db"
+)
+
+// ThrottledError limit of rule is reached
+type ThrottledError struct {
+	Rule       string
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("too many requests, rule %v, retry after %v", e.Rule, e.RetryAfter.Round(time.Second))
+}
+
+// ThrottleRequest passcode request to check
+type ThrottleRequest struct {
+	Channel string
+	To      string
+	IP      string
+}
+
+// Throttler abuse limits of passcode endpoints
+type Throttler interface {
+	// Allow count request, *ThrottledError if any rule limit is reached, request is not counted then
+	Allow(req ThrottleRequest) error
+}
+
+// throttleHit request counted by rule
+type throttleHit struct {
+	rule   string
+	key    string
+	limit  int
+	window time.Duration
+}
+
+// throttleStore sliding window log
+type throttleStore interface {
+	// take all hits or none, rule of first exceeded hit and retry after returned
+	take(hits []throttleHit, now time.Time) (string, time.Duration, error)
+}
+
+type throttler struct {
+	rules []config.AppConfigThrottleRule
+	store throttleStore
+	now   func() time.Time
+}
+
+// NewThrottler new throttler by config, panic on bad config
+func NewThrottler(appConfig *config.AppConfig, repo repository.AppRepository) Throttler {
+
+	cfg := appConfig.Messenger.Throttle
+
+	rules := make([]config.AppConfigThrottleRule, 0, len(cfg.Rules))
+
+	for _, rule := range cfg.Rules {
+
+		if !slices.Contains([]string{ThrottleKeyRecipient, ThrottleKeyIP, ThrottleKeyCountry}, rule.Key) {
+			panic(fmt.Errorf("error throttle rule key not supported: %v", rule.Key))
+		}
+		if rule.Limit <= 0 || rule.Window <= 0 {
+			panic(fmt.Errorf("error throttle rule %v: limit and window must be positive", rule.Key))
+		}
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("%v_%v_%v", rule.Key, rule.Limit, rule.Window)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	res := &throttler{rules: rules, now: time.Now}
+
+	window := time.Duration(0)
+	for _, rule := range rules {
+		window = max(window, time.Duration(rule.Window)*time.Second)
+	}
+
+	switch cfg.Store {
+	case ThrottleStoreMemory, "":
+		res.store = newMemoryThrottleStore()
+	case ThrottleStoreDB:
+		res.store = &dbThrottleStore{repository: repo, window: window}
+	default:
+		panic(fmt.Errorf("error throttle store not supported: %v", cfg.Store))
+	}
+
+	return res
+}
+
+// Allow count request by all matched rules
+func (x *throttler) Allow(req ThrottleRequest) error {
+
+	hits := x.hits(req)
+	if len(hits) == 0 {
+		return nil
+	}
+
+	rule, retryAfter, err := x.store.take(hits, x.now())
+	if err != nil {
+		return err
+	}
+
+	if rule != "" {
+		metricThrottle.WithLabelValues(req.Channel, rule, "throttled").Inc()
+		return &ThrottledError{Rule: rule, RetryAfter: retryAfter}
+	}
+
+	for _, hit := range hits {
+		metricThrottle.WithLabelValues(req.Channel, hit.rule, "allowed").Inc()
+	}
+
+	return nil
+}
+
+// hits matched rules with key values of request
+func (x *throttler) hits(req ThrottleRequest) []throttleHit {
+
+	res := []throttleHit{}
+
+	for _, rule := range x.rules {
+
+		if rule.Channel != "" && rule.Channel != req.Channel {
+			continue
+		}
+
+		if len(rule.Prefix) > 0 && (req.Channel != ChannelSms || !slices.ContainsFunc(rule.Prefix, func(v string) bool {
+			return strings.HasPrefix(normalizePhonePrefix(req.To), normalizePhonePrefix(v))
+		})) {
+			continue
+		}
+
+		var value string
+
+		switch rule.Key {
+		case ThrottleKeyRecipient:
+			value = strings.ToLower(strings.TrimSpace(req.To))
+			if req.Channel == ChannelSms {
+				value = normalizePhonePrefix(req.To)
+			}
+		case ThrottleKeyIP:
+			value = req.IP
+		case ThrottleKeyCountry:
+			if req.Channel == ChannelSms {
+				value = utilphone.CountryCode(req.To)
+			}
+		}
+
+		if value == "" {
+			continue
+		}
+
+		res = append(res, throttleHit{
+			rule:   rule.Name,
+			key:    rule.Name + ":" + req.Channel + ":" + value,
+			limit:  rule.Limit,
+			window: time.Duration(rule.Window) * time.Second,
+		})
+	}
+
+	return res
+}
+
+// memoryThrottleStore single replica store
+type memoryThrottleStore struct {
+	mu        sync.Mutex
+	hits      map[string][]time.Time
+	windows   map[string]time.Duration
+	lastSweep time.Time
+}
+
+func newMemoryThrottleStore() *memoryThrottleStore {
+	return &memoryThrottleStore{
+		hits:    map[string][]time.Time{},
+		windows: map[string]time.Duration{},
+	}
+}
+
+func (x *memoryThrottleStore) take(hits []throttleHit, now time.Time) (string, time.Duration, error) {
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.sweep(now)
+
+	for _, hit := range hits {
+
+		times := inWindow(x.hits[hit.key], hit.window, now)
+		x.hits[hit.key] = times
+
+		if len(times) >= hit.limit {
+			return hit.rule, times[len(times)-hit.limit].Add(hit.window).Sub(now), nil
+		}
+	}
+
+	for _, hit := range hits {
+		x.hits[hit.key] = append(x.hits[hit.key], now)
+		x.windows[hit.key] = hit.window
+	}
+
+	return "", 0, nil
+}
+
+// sweep drop keys without hits in window, not often than once a minute
+func (x *memoryThrottleStore) sweep(now time.Time) {
+
+	if now.Sub(x.lastSweep) < time.Minute {
+		return
+	}
+
+	x.lastSweep = now
+
+	for k, times := range x.hits {
+		if len(inWindow(times, x.windows[k], now)) == 0 {
+			delete(x.hits, k)
+			delete(x.windows, k)
+		}
+	}
+}
+
+// inWindow hits after now-window, times are ordered
+func inWindow(times []time.Time, window time.Duration, now time.Time) []time.Time {
+
+	from := now.Add(-window)
+
+	i := 0
+	for i < len(times) && !times[i].After(from) {
+		i++
+	}
+
+	return times[i:]
+}
+
+// ThrottleHit request counted by throttle rule
+type ThrottleHit struct {
+	ID        int64     `gorm:"primaryKey"`
+	Key       string    `gorm:"size:255;index:idx_throttle_key_created"`
+	CreatedAt time.Time `gorm:"index:idx_throttle_key_created;index"`
+}
+
+// dbThrottleStore store shared by replicas, keys are locked in transaction
+type dbThrottleStore struct {
+	repository repository.AppRepository
+	window     time.Duration // longest rule window
+	mu         sync.Mutex
+	lastSweep  time.Time
+}
+
+func (x *dbThrottleStore) take(hits []throttleHit, now time.Time) (string, time.Duration, error) {
+
+	if err := x.sweep(now); err != nil {
+		return "", 0, fmt.Errorf("throttle store: %v", err)
+	}
+
+	var rule string
+	var retryAfter time.Duration
+
+	// lock keys in same order, no deadlock
+	sorted := slices.Clone(hits)
+	slices.SortFunc(sorted, func(a, b throttleHit) int { return strings.Compare(a.key, b.key) })
+
+	err := x.repository.Transaction(func(tx repository.AppRepository) error {
+
+		for _, hit := range sorted {
+			if err := tx.Exec("select pg_advisory_xact_lock(hashtext(?))", hit.key).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, hit := range hits {
+
+			from := now.Add(-hit.window)
+
+			if err := tx.Where("key = ? and created_at <= ?", hit.key, from).Delete(&ThrottleHit{}).Error; err != nil {
+				return err
+			}
+
+			times := []time.Time{}
+
+			err := tx.Model(&ThrottleHit{}).Where("key = ? and created_at > ?", hit.key, from).
+				Order("created_at desc").Limit(hit.limit).Pluck("created_at", &times).Error
+			if err != nil {
+				return err
+			}
+
+			if len(times) >= hit.limit {
+				rule = hit.rule
+				retryAfter = times[len(times)-1].Add(hit.window).Sub(now)
+				return nil
+			}
+		}
+
+		rows := make([]ThrottleHit, 0, len(hits))
+		for _, hit := range hits {
+			rows = append(rows, ThrottleHit{Key: hit.key, CreatedAt: now})
+		}
+
+		return tx.Create(&rows).Error
+	})
+
+	if err != nil {
+		return "", 0, fmt.Errorf("throttle store: %v", err)
+	}
+
+	return rule, retryAfter, nil
+}
+
+// sweep drop hits older than longest window of all keys, keys not requested again are dropped too,
+// not often than once a minute by replica
+func (x *dbThrottleStore) sweep(now time.Time) error {
+
+	x.mu.Lock()
+	if now.Sub(x.lastSweep) < time.Minute {
+		x.mu.Unlock()
+		return nil
+	}
+	x.lastSweep = now
+	x.mu.Unlock()
+
+	// no key is locked, hits out of every window are not counted
+	return x.repository.Where("created_at <= ?", now.Add(-x.window)).Delete(&ThrottleHit{}).Error
+}
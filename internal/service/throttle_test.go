@@ -0,0 +1,93 @@
+package service
+
+import (
+	"errors"
+	"go-infra/internal/config"
+	"testing"
+	"time"
+)
+
+func newTestThrottler(t *testing.T, rules ...config.AppConfigThrottleRule) (*throttler, *time.Time) {
+	appConfig := &config.AppConfig{}
+	appConfig.Messenger.Throttle = config.AppConfigThrottle{Store: ThrottleStoreMemory, Rules: rules}
+
+	x := NewThrottler(appConfig, nil).(*throttler)
+
+	now := time.Now()
+	x.now = func() time.Time { return now }
+
+	return x, &now
+}
+
+// Test sliding window limit per recipient with retry after
+func TestThrottler_SlidingWindow(t *testing.T) {
+	x, now := newTestThrottler(t, config.AppConfigThrottleRule{Key: ThrottleKeyRecipient, Limit: 3, Window: 600})
+
+	req := ThrottleRequest{Channel: ChannelSms, To: "+1 202 555 0100", IP: "10.0.0.1"}
+
+	for i := 0; i < 3; i++ {
+		if err := x.Allow(req); err != nil {
+			t.Fatalf("Request %d: unexpected %v", i, err)
+		}
+		*now = now.Add(time.Minute)
+	}
+
+	var throttled *ThrottledError
+	if err := x.Allow(req); !errors.As(err, &throttled) {
+		t.Fatalf("Expected throttled, got %v", err)
+	}
+	if throttled.RetryAfter != 7*time.Minute || throttled.Rule != "recipient_3_600" {
+		t.Errorf("Unexpected throttled error: %+v", throttled)
+	}
+
+	// same number in other format is same recipient, other number is not limited
+	if err := x.Allow(ThrottleRequest{Channel: ChannelSms, To: "+12025550100"}); err == nil {
+		t.Error("Expected throttled for same number")
+	}
+	if err := x.Allow(ThrottleRequest{Channel: ChannelSms, To: "+12025550101"}); err != nil {
+		t.Errorf("Unexpected %v", err)
+	}
+
+	// first hit leaves window
+	*now = now.Add(7 * time.Minute)
+	if err := x.Allow(req); err != nil {
+		t.Errorf("Expected allowed after window, got %v", err)
+	}
+}
+
+// Test rejected request is not counted by other rules
+func TestThrottler_AllOrNone(t *testing.T) {
+	x, _ := newTestThrottler(t,
+		config.AppConfigThrottleRule{Key: ThrottleKeyIP, Limit: 2, Window: 60},
+		config.AppConfigThrottleRule{Key: ThrottleKeyRecipient, Limit: 1, Window: 60},
+	)
+
+	_ = x.Allow(ThrottleRequest{Channel: ChannelEmail, To: "a@example.com", IP: "10.0.0.1"})
+
+	if err := x.Allow(ThrottleRequest{Channel: ChannelEmail, To: "A@example.com", IP: "10.0.0.1"}); err == nil {
+		t.Fatal("Expected throttled by recipient")
+	}
+	if err := x.Allow(ThrottleRequest{Channel: ChannelEmail, To: "b@example.com", IP: "10.0.0.1"}); err != nil {
+		t.Errorf("Expected rejected request not counted by ip, got %v", err)
+	}
+}
+
+// Test country key and prefix filter
+func TestThrottler_Country(t *testing.T) {
+	x, _ := newTestThrottler(t,
+		config.AppConfigThrottleRule{Key: ThrottleKeyCountry, Limit: 1, Window: 60, Prefix: []string{"+44"}},
+	)
+
+	if err := x.Allow(ThrottleRequest{Channel: ChannelSms, To: "+447700900001"}); err != nil {
+		t.Fatalf("Unexpected %v", err)
+	}
+	if err := x.Allow(ThrottleRequest{Channel: ChannelSms, To: "+447700900002"}); err == nil {
+		t.Error("Expected throttled by country")
+	}
+	if err := x.Allow(ThrottleRequest{Channel: ChannelSms, To: "+12025550100"}); err != nil {
+		t.Errorf("Expected other country not limited, got %v", err)
+	}
+	if err := x.Allow(ThrottleRequest{Channel: ChannelEmail, To: "a@example.com"}); err != nil {
+		t.Errorf("Expected email not limited, got %v", err)
+	}
+}
@@ -3,9 +3,11 @@ package utilconfig
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"go-infra/internal/util/utilhttp"
 	xlog "go-infra/internal/util/utillog"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -160,3 +162,35 @@ func fromJSON(cfgPtr any, data string) error {
 
 	return nil
 }
+
+// LoadText text file from dir or URL, false if file not exists
+func LoadText(dir string, fileName string) (string, bool, error) {
+
+	if strings.HasPrefix(dir, "http") {
+
+		data, err := utilhttp.GetBytes(dir+"/"+fileName, nil, nil)
+
+		var statusErr *utilhttp.StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+			return "", false, nil
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("error with file %v/%v: %v", dir, fileName, err)
+		}
+
+		return string(data), true, nil
+	}
+
+	fullPath := filepath.Clean(filepath.Join(dir, fileName))
+
+	data, err := os.ReadFile(fullPath)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("error with file %v: %v", fullPath, err)
+	}
+
+	return string(data), true, nil
+}
@@ -16,6 +16,15 @@ func URLEncode(input string) string {
 	return url.QueryEscape(input)
 }
 
+// StatusError http response with non-200 status
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("error on http resp check: %v", e.StatusCode)
+}
+
 // Resp http basic response
 type Resp struct {
 	StatusCode int
@@ -93,8 +102,8 @@ func PostJSON(baseURL string, queryParams map[string]string,
 		return nil, fmt.Errorf("error reading response body: %v", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return body, fmt.Errorf("error on http resp check: %v", resp.StatusCode)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return body, &StatusError{StatusCode: resp.StatusCode}
 	}
 
 	return body, err
@@ -155,13 +164,58 @@ func PostFormURL(baseURL string, queryParams map[string]string,
 		return nil, fmt.Errorf("error reading response body: %v", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return body, fmt.Errorf("error on http resp check: %v", resp.StatusCode)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return body, &StatusError{StatusCode: resp.StatusCode}
 	}
 
 	return body, err
 }
 
+// Send send request with raw body, nil body allowed
+func Send(method string, URL string,
+	headers map[string]string, body []byte,
+) ([]byte, error) {
+
+	var data io.Reader
+
+	if body != nil {
+		data = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, URL, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(headers) != 0 {
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+	}
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+
+	if err != nil {
+
+		return nil, fmt.Errorf("error sending request: %v", err)
+	}
+
+	defer resp.Body.Close()
+
+	// Read the response
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %v", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return respBody, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	return respBody, err
+}
+
 func GetBytes(baseURL string, queryParams map[string]string,
 	headers map[string]string,
 ) ([]byte, error) {
@@ -201,7 +255,7 @@ func GetBytes(baseURL string, queryParams map[string]string,
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return body, fmt.Errorf("error on http resp check: %v", resp.StatusCode)
+		return body, &StatusError{StatusCode: resp.StatusCode}
 	}
 
 	return body, err
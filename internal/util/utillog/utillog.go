@@ -3,9 +3,11 @@ package utillog
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"log/slog"
 	"os"
+	"sync/atomic"
 )
 
 // not work in win
@@ -27,19 +29,58 @@ var DefaultLogger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
 
 // var DefaultLogger = log.New(os.Stdout, "", log.LUTC)
 
-func Info(format string, v ...any) {
+var redactor atomic.Pointer[func(string) string]
+
+// SetRedactor mask sensitive data of every message, nil no masking
+func SetRedactor(fn func(msg string) string) {
+	if fn == nil {
+		redactor.Store(nil)
+		return
+	}
+	redactor.Store(&fn)
+}
+
+type redactWriter struct {
+	w io.Writer
+}
+
+func (x redactWriter) Write(p []byte) (int, error) {
+	if fn := redactor.Load(); fn != nil {
+		if _, err := x.w.Write([]byte((*fn)(string(p)))); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	return x.w.Write(p)
+}
+
+// RedactWriter writer with masking of sensitive data, for output not written by this package
+func RedactWriter(w io.Writer) io.Writer {
+	return redactWriter{w: w}
+}
+
+// sprintf formatted and redacted message
+func sprintf(format string, v ...any) string {
 	msg := fmt.Sprintf(format, v...)
+	if fn := redactor.Load(); fn != nil {
+		return (*fn)(msg)
+	}
+	return msg
+}
+
+func Info(format string, v ...any) {
+	msg := sprintf(format, v...)
 	DefaultLogger.Info(msg)
 }
 
 func Error(format string, v ...any) {
-	msg := fmt.Sprintf(format, v...)
+	msg := sprintf(format, v...)
 	DefaultLogger.Error(msg)
 
 }
 
 func Panic(format string, v ...any) {
-	msg := fmt.Sprintf(format, v...)
+	msg := sprintf(format, v...)
 	DefaultLogger.Error(msg)
 
 	log.Panic(msg)
@@ -47,13 +88,13 @@ func Panic(format string, v ...any) {
 }
 func Debug(format string, v ...any) {
 
-	msg := fmt.Sprintf(format, v...)
+	msg := sprintf(format, v...)
 	DefaultLogger.Debug(msg)
 }
 
 func Warn(format string, v ...any) {
 
-	msg := fmt.Sprintf(format, v...)
+	msg := sprintf(format, v...)
 	DefaultLogger.Warn(msg)
 }
 
@@ -0,0 +1,136 @@
+// Package utilphone phone number tool
+package utilphone
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalid number can not be normalized
+var ErrInvalid = errors.New("invalid phone number")
+
+// two digit country calling codes, ITU-T E.164, codes are prefix free
+var countryCodes2 = map[string]bool{
+	"20": true, "27": true, "30": true, "31": true, "32": true, "33": true, "34": true, "36": true, "39": true,
+	"40": true, "41": true, "43": true, "44": true, "45": true, "46": true, "47": true, "48": true, "49": true,
+	"51": true, "52": true, "53": true, "54": true, "55": true, "56": true, "57": true, "58": true,
+	"60": true, "61": true, "62": true, "63": true, "64": true, "65": true, "66": true,
+	"81": true, "82": true, "84": true, "86": true,
+	"90": true, "91": true, "92": true, "93": true, "94": true, "95": true, "98": true,
+}
+
+// country calling codes with leading 0 kept in national significant number, no trunk prefix
+var trunkZeroKept = map[string]bool{
+	"39": true, "225": true, "242": true,
+}
+
+// Digits digits only
+func Digits(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, value)
+}
+
+// International digits of number in international format, + or 00 prefix, false for national format
+func International(value string) (string, bool) {
+
+	value = strings.TrimSpace(value)
+
+	switch {
+	case strings.HasPrefix(value, "+"):
+		return Digits(value), true
+	case strings.HasPrefix(value, "00"):
+		return strings.TrimPrefix(Digits(value), "00"), true
+	}
+
+	return "", false
+}
+
+// CountryCode country calling code of number in international format, empty if unknown
+func CountryCode(value string) string {
+
+	digits, ok := International(value)
+	if !ok || len(digits) < 4 {
+		return ""
+	}
+
+	switch {
+	case digits[0] == '1' || digits[0] == '7':
+		return digits[:1]
+	case countryCodes2[digits[:2]]:
+		return digits[:2]
+	case digits[0] == '0':
+		return ""
+	}
+
+	return digits[:3]
+}
+
+// RegionCode country calling code of ISO 3166-1 alpha-2 region, empty if unknown
+func RegionCode(region string) string {
+	return regionCodes[strings.ToUpper(strings.TrimSpace(region))]
+}
+
+// Normalize number to E.164 +digits, national format number is completed by default region
+func Normalize(value string, defaultRegion string) (string, error) {
+
+	value = strings.TrimSpace(value)
+
+	if strings.Trim(value, "0123456789+-(). ") != "" || strings.LastIndex(value, "+") > 0 {
+		return "", fmt.Errorf("%w: unexpected chars", ErrInvalid)
+	}
+
+	digits, ok := International(value)
+
+	if !ok {
+		code := RegionCode(defaultRegion)
+		if code == "" {
+			return "", fmt.Errorf("%w: international format expected", ErrInvalid)
+		}
+		digits = nationalDigits(Digits(value), code)
+		if digits == "" {
+			return "", fmt.Errorf("%w: empty number", ErrInvalid)
+		}
+		digits = code + digits
+	}
+
+	code := CountryCode("+" + digits)
+
+	// trunk prefix written in international format, +44 (0)20 7946 0000
+	if code != "" && ok && !trunkZeroKept[code] && strings.HasPrefix(digits[len(code):], "0") {
+		digits = code + digits[len(code)+1:]
+	}
+
+	// E.164 max 15 digits, shortest national numbers have 4 digits
+	if code == "" || len(digits) > 15 || len(digits)-len(code) < 4 {
+		return "", fmt.Errorf("%w: length or country code", ErrInvalid)
+	}
+
+	return "+" + digits, nil
+}
+
+// nationalDigits national significant number without trunk prefix
+func nationalDigits(digits string, code string) string {
+
+	switch code {
+	case "1":
+		if len(digits) == 11 && digits[0] == '1' {
+			return digits[1:]
+		}
+		return digits
+	case "7":
+		if len(digits) == 11 && digits[0] == '8' {
+			return digits[1:]
+		}
+	}
+
+	if trunkZeroKept[code] {
+		return digits // italian numbers keep leading 0
+	}
+
+	return strings.TrimPrefix(digits, "0")
+}
@@ -0,0 +1,57 @@
+package utilphone
+
+import "testing"
+
+// Test country calling code by prefix
+func TestCountryCode(t *testing.T) {
+	cases := map[string]string{
+		"+1 202 555 0100":  "1",
+		"+7 495 123 45 67": "7",
+		"+44 20 7946 0000": "44",
+		"0049301234567":    "49",
+		"+380441234567":    "380",
+		"+2125221234":      "212",
+		"202 555 0100":     "",
+		"+12":              "",
+		"":                 "",
+	}
+
+	for phone, expected := range cases {
+		if code := CountryCode(phone); code != expected {
+			t.Errorf("CountryCode(%q): expected %q, got %q", phone, expected, code)
+		}
+	}
+}
+
+// Test E.164 normalization with default region
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		value    string
+		region   string
+		expected string
+	}{
+		{"+1 (202) 555-0100", "", "+12025550100"},
+		{"0044 20 7946 0000", "", "+442079460000"},
+		{"020 7946 0000", "GB", "+442079460000"},
+		{"(202) 555-0100", "us", "+12025550100"},
+		{"1 202 555 0100", "US", "+12025550100"},
+		{"8 495 123 45 67", "RU", "+74951234567"},
+		{"06 1234 5678", "IT", "+390612345678"},
+		{"+44 (0)7700 900123", "", "+447700900123"},
+		{"0044 (0)20 7946 0000", "", "+442079460000"},
+		{"+39 06 1234 5678", "", "+390612345678"},
+		{"202 555 0100", "", ""},
+		{"202 555 0100", "XX", ""},
+		{"+1 202 555 0100 ext 5", "", ""},
+		{"+44 123", "", ""},
+		{"+1234567890123456", "", ""},
+		{"12+345678", "US", ""},
+	}
+
+	for _, c := range cases {
+		res, err := Normalize(c.value, c.region)
+		if res != c.expected || (c.expected == "") != (err != nil) {
+			t.Errorf("Normalize(%q, %q): expected %q, got %q %v", c.value, c.region, c.expected, res, err)
+		}
+	}
+}
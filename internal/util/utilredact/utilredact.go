@@ -0,0 +1,135 @@
+// Package utilredact mask sensitive data in text
+package utilredact
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Mask replacement of secret values
+const Mask = "****"
+
+var (
+	rePhone = regexp.MustCompile(`(?:\+|%2[Bb])[1-9]\d{6,14}\b`) // + is url encoded in logged uri
+	reEmail = regexp.MustCompile(`[A-Za-z0-9._%+\-]+(?:@|%40)[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+	// phone without prefix as whole value of phone query param, ?to=447700900123, ids are kept
+	reQueryPhone = regexp.MustCompile(`[?&](?i:to|phone|msisdn|mobile|tel|recipient)=([1-9]\d{6,14})\b`)
+)
+
+// Policy mask phones, emails and patterns
+type Policy struct {
+	Phone    bool
+	Email    bool
+	Patterns []*regexp.Regexp // group 1 or whole match is masked
+}
+
+// NewPolicy policy with compiled patterns
+func NewPolicy(phone bool, email bool, patterns []string) (*Policy, error) {
+
+	res := &Policy{Phone: phone, Email: email}
+
+	for _, v := range patterns {
+		re, err := regexp.Compile(v)
+		if err != nil {
+			return nil, fmt.Errorf("redact pattern %q: %v", v, err)
+		}
+		res.Patterns = append(res.Patterns, re)
+	}
+
+	return res, nil
+}
+
+// Redact mask sensitive data of text
+func (x *Policy) Redact(text string) string {
+
+	for _, re := range x.Patterns {
+		text = maskPattern(re, text)
+	}
+
+	if x.Phone {
+		text = rePhone.ReplaceAllStringFunc(text, Phone)
+		text = replaceGroup(reQueryPhone, text, Phone)
+	}
+
+	if x.Email {
+		text = reEmail.ReplaceAllStringFunc(text, Email)
+	}
+
+	return text
+}
+
+// maskPattern mask group 1 of match or whole match
+func maskPattern(re *regexp.Regexp, text string) string {
+
+	if re.NumSubexp() == 0 {
+		return re.ReplaceAllString(text, Mask)
+	}
+
+	return replaceGroup(re, text, func(string) string { return Mask })
+}
+
+// replaceGroup replace group 1 of every match
+func replaceGroup(re *regexp.Regexp, text string, replace func(string) string) string {
+
+	bu := strings.Builder{}
+	last := 0
+
+	for _, loc := range re.FindAllStringSubmatchIndex(text, -1) {
+		if loc[2] < 0 {
+			continue
+		}
+		bu.WriteString(text[last:loc[2]])
+		bu.WriteString(replace(text[loc[2]:loc[3]]))
+		last = loc[3]
+	}
+
+	bu.WriteString(text[last:])
+
+	return bu.String()
+}
+
+// Phone keep country prefix and last two digits, +44*******00, %2B44*******00 url encoded
+func Phone(value string) string {
+
+	head := 2
+	switch {
+	case strings.HasPrefix(value, "+"):
+		head = 3
+	case strings.HasPrefix(strings.ToUpper(value), "%2B"):
+		head = 5
+	}
+
+	if len(value) <= head+2 {
+		return Mask
+	}
+
+	return value[:head] + strings.Repeat("*", len(value)-head-2) + value[len(value)-2:]
+}
+
+// Email keep first letter and domain, j***@example.com, j***%40example.com url encoded
+func Email(value string) string {
+
+	i := strings.LastIndex(value, "@")
+	if i < 0 {
+		i = strings.LastIndex(value, "%40")
+	}
+	if i <= 0 {
+		return Mask
+	}
+
+	return value[:1] + "***" + value[i:]
+}
+
+// Secrets replace every secret value in text
+func Secrets(text string, secrets ...string) string {
+
+	for _, v := range secrets {
+		if v != "" {
+			text = strings.ReplaceAll(text, v, Mask)
+		}
+	}
+
+	return text
+}
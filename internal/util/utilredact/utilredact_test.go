@@ -0,0 +1,78 @@
+package utilredact
+
+import "testing"
+
+func TestPolicy_Redact(t *testing.T) {
+
+	policy, err := NewPolicy(true, true, []string{`(?i)code\W{1,3}(\w{4,12})`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"to: `+12025550100` message: `Secret code: 123456`", "to: `+12*******00` message: `Secret code: ****`"},
+		{"to: `john.doe@example.com`", "to: `j***@example.com`"},
+		{"date 2026-10-18 port 5432", "date 2026-10-18 port 5432"},
+	}
+
+	for _, itm := range cases {
+		if res := policy.Redact(itm.in); res != itm.want {
+			t.Errorf("Redact(%q) = %q, want %q", itm.in, res, itm.want)
+		}
+	}
+
+	if _, err := NewPolicy(false, false, []string{"("}); err == nil {
+		t.Error("Expected error on bad pattern")
+	}
+}
+
+func TestSecrets(t *testing.T) {
+
+	if res := Secrets("<b>987654</b> 987654", "987654", ""); res != "<b>****</b> ****" {
+		t.Errorf("Unexpected %q", res)
+	}
+
+	if res := Phone("+4420"); res != Mask {
+		t.Errorf("Expected short phone masked, got %q", res)
+	}
+}
+
+func TestPolicy_RedactAccessLog(t *testing.T) {
+
+	policy, _ := NewPolicy(true, true, nil)
+
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{
+			`{"method":"GET","uri":"/sys/api/messenger?service_code=sms_passcode&to=%2B447700900123&lang=en","status":200}`,
+			`{"method":"GET","uri":"/sys/api/messenger?service_code=sms_passcode&to=%2B44********23&lang=en","status":200}`,
+		},
+		{
+			`{"method":"GET","uri":"/sys/api/messenger?to=447700900123&service_code=sms_passcode","status":200}`,
+			`{"method":"GET","uri":"/sys/api/messenger?to=44********23&service_code=sms_passcode","status":200}`,
+		},
+		{
+			`{"method":"GET","uri":"/sys/api/messenger?service_code=email_passcode&to=john.doe%40example.com","status":200}`,
+			`{"method":"GET","uri":"/sys/api/messenger?service_code=email_passcode&to=j***%40example.com","status":200}`,
+		},
+		{
+			`{"method":"GET","uri":"/sys/api/messenger/spend?order_id=12345678901&Phone=12025550100","status":200}`,
+			`{"method":"GET","uri":"/sys/api/messenger/spend?order_id=12345678901&Phone=12*******00","status":200}`,
+		},
+		{
+			`{"method":"GET","uri":"/sys/api/messenger/messages/4f9a?limit=100","status":200}`,
+			`{"method":"GET","uri":"/sys/api/messenger/messages/4f9a?limit=100","status":200}`,
+		},
+	}
+
+	for _, itm := range cases {
+		if res := policy.Redact(itm.in); res != itm.want {
+			t.Errorf("Redact(%q)\n = %q\nwant %q", itm.in, res, itm.want)
+		}
+	}
+}
@@ -0,0 +1,377 @@
+package utilsmtp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Message email message
+type Message struct {
+	ID          string // optional, used in Message-ID header
+	From        string // `title <mail>` or `mail`
+	To          []string
+	Cc          []string
+	Bcc         []string // envelope only, no header
+	ReplyTo     string
+	Subject     string
+	HTML        string
+	Text        string            // optional plain text alternative
+	Headers     map[string]string // custom headers, X-Campaign: spring
+	Attachments []Attachment
+	Date        time.Time // optional, default now
+}
+
+// Attachment file attached to message, inline image if ContentID is set, <img src="cid:ContentID">
+type Attachment struct {
+	Filename    string
+	ContentType string // optional, by filename extension
+	ContentID   string
+	Data        []byte
+}
+
+// MessageError message can not be encoded
+type MessageError struct {
+	Err error
+}
+
+func (e *MessageError) Error() string { return e.Err.Error() }
+func (e *MessageError) Unwrap() error { return e.Err }
+
+// headers set by message, can not be custom
+var reservedHeaders = []string{
+	"From", "To", "Cc", "Bcc", "Reply-To", "Subject", "Date", "Message-Id", "Mime-Version",
+	"Content-Type", "Content-Transfer-Encoding", "Content-Disposition", "Content-Id",
+}
+
+var headerNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*$`)
+
+// mimePart encoded part of message
+type mimePart struct {
+	header textproto.MIMEHeader
+	body   []byte
+}
+
+// BuildMessage encode message in MIME format
+func BuildMessage(msg Message) ([]byte, error) {
+
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return nil, &MessageError{fmt.Errorf("error from address %q: %v", msg.From, err)}
+	}
+
+	to, err := headerAddresses("to", msg.To)
+	if err != nil {
+		return nil, err
+	}
+
+	cc, err := headerAddresses("cc", msg.Cc)
+	if err != nil {
+		return nil, err
+	}
+
+	date := msg.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	body, err := messageBody(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	bu := bytes.Buffer{}
+
+	writeHeader(&bu, "From", from.String())
+	if len(to) > 0 {
+		writeHeader(&bu, "To", strings.Join(to, ", "))
+	}
+	if len(cc) > 0 {
+		writeHeader(&bu, "Cc", strings.Join(cc, ", "))
+	}
+	if msg.ReplyTo != "" {
+		replyTo, err := mail.ParseAddress(msg.ReplyTo)
+		if err != nil {
+			return nil, &MessageError{fmt.Errorf("error reply-to address %q: %v", msg.ReplyTo, err)}
+		}
+		writeHeader(&bu, "Reply-To", replyTo.String())
+	}
+	writeHeader(&bu, "Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	writeHeader(&bu, "Date", date.Format(time.RFC1123Z))
+	writeHeader(&bu, "Message-ID", messageID(msg.ID, from.Address))
+	writeHeader(&bu, "MIME-Version", "1.0")
+
+	if err := writeCustomHeaders(&bu, msg.Headers); err != nil {
+		return nil, err
+	}
+
+	for _, k := range sortedKeys(body.header) {
+		writeHeader(&bu, k, body.header.Get(k))
+	}
+	bu.WriteString("\r\n")
+	bu.Write(body.body)
+
+	return bu.Bytes(), nil
+}
+
+// messageBody mixed(alternative(text, related(html, inline)), attachments), not needed levels are skipped
+func messageBody(msg Message) (mimePart, error) {
+
+	inline := []mimePart{}
+	attached := []mimePart{}
+
+	for _, itm := range msg.Attachments {
+		part, err := attachmentPart(itm)
+		if err != nil {
+			return mimePart{}, err
+		}
+		if itm.ContentID != "" {
+			inline = append(inline, part)
+		} else {
+			attached = append(attached, part)
+		}
+	}
+
+	res, err := textPart("text/html", msg.HTML)
+	if err != nil {
+		return res, err
+	}
+
+	if len(inline) > 0 {
+		res, err = multipartPart("related", append([]mimePart{res}, inline...))
+		if err != nil {
+			return res, err
+		}
+	}
+
+	if msg.Text != "" {
+		text, err := textPart("text/plain", msg.Text)
+		if err != nil {
+			return res, err
+		}
+		res, err = multipartPart("alternative", []mimePart{text, res})
+		if err != nil {
+			return res, err
+		}
+	}
+
+	if len(attached) > 0 {
+		res, err = multipartPart("mixed", append([]mimePart{res}, attached...))
+	}
+
+	return res, err
+}
+
+func textPart(contentType string, text string) (mimePart, error) {
+
+	bu := bytes.Buffer{}
+	if err := writeQuotedPrintable(&bu, text); err != nil {
+		return mimePart{}, err
+	}
+
+	return mimePart{
+		header: textproto.MIMEHeader{
+			"Content-Type":              {contentType + `; charset="utf-8"`},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		},
+		body: bu.Bytes(),
+	}, nil
+}
+
+func attachmentPart(itm Attachment) (mimePart, error) {
+
+	name := filepath.Base(strings.NewReplacer("\r", "", "\n", "").Replace(itm.Filename))
+	if itm.Filename == "" || name == "." || name == "/" {
+		return mimePart{}, &MessageError{fmt.Errorf("error attachment filename is empty")}
+	}
+
+	contentType := itm.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(name))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return mimePart{}, &MessageError{fmt.Errorf("error attachment %q content type: %v", name, err)}
+	}
+	params["name"] = name
+
+	disposition := "attachment"
+	if itm.ContentID != "" {
+		disposition = "inline"
+	}
+
+	header := textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType(mediaType, params)},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType(disposition, map[string]string{"filename": name})},
+	}
+
+	if itm.ContentID != "" {
+		header.Set("Content-ID", "<"+strings.Trim(itm.ContentID, "<>")+">")
+	}
+
+	return mimePart{header: header, body: base64Lines(itm.Data)}, nil
+}
+
+func multipartPart(subtype string, parts []mimePart) (mimePart, error) {
+
+	bu := bytes.Buffer{}
+	w := multipart.NewWriter(&bu)
+
+	for _, part := range parts {
+		pw, err := w.CreatePart(part.header)
+		if err != nil {
+			return mimePart{}, err
+		}
+		if _, err := pw.Write(part.body); err != nil {
+			return mimePart{}, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return mimePart{}, err
+	}
+
+	params := map[string]string{"boundary": w.Boundary()}
+	if subtype == "related" {
+		params["type"] = "text/html"
+	}
+
+	return mimePart{
+		header: textproto.MIMEHeader{"Content-Type": {mime.FormatMediaType("multipart/"+subtype, params)}},
+		body:   bu.Bytes(),
+	}, nil
+}
+
+// base64Lines base64 with 76 chars lines
+func base64Lines(data []byte) []byte {
+
+	encoded := base64.StdEncoding.EncodeToString(data)
+	bu := bytes.Buffer{}
+
+	for len(encoded) > 76 {
+		bu.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	bu.WriteString(encoded + "\r\n")
+
+	return bu.Bytes()
+}
+
+func headerAddresses(name string, list []string) ([]string, error) {
+
+	res := make([]string, 0, len(list))
+
+	for _, v := range list {
+		addr, err := mail.ParseAddress(v)
+		if err != nil {
+			return nil, &MessageError{fmt.Errorf("error %v address %q: %v", name, v, err)}
+		}
+		res = append(res, addr.String())
+	}
+
+	return res, nil
+}
+
+// CheckHeaders custom headers must be valid names and not set by message itself
+func CheckHeaders(headers map[string]string) error {
+
+	for k := range headers {
+		if !headerNameRe.MatchString(k) || slices.Contains(reservedHeaders, textproto.CanonicalMIMEHeaderKey(k)) {
+			return &MessageError{fmt.Errorf("error custom header not allowed: %q", k)}
+		}
+	}
+
+	return nil
+}
+
+func writeCustomHeaders(bu *bytes.Buffer, headers map[string]string) error {
+
+	if err := CheckHeaders(headers); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	for _, k := range keys {
+		writeHeader(bu, textproto.CanonicalMIMEHeaderKey(k), mime.QEncoding.Encode("utf-8", headers[k]))
+	}
+
+	return nil
+}
+
+func sortedKeys(header textproto.MIMEHeader) []string {
+	res := make([]string, 0, len(header))
+	for k := range header {
+		res = append(res, k)
+	}
+	slices.Sort(res)
+	return res
+}
+
+func writeHeader(bu *bytes.Buffer, name string, value string) {
+	// drop line breaks, protect from header injection
+	value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+	bu.WriteString(name + ": " + value + "\r\n")
+}
+
+func writeQuotedPrintable(bu *bytes.Buffer, text string) error {
+
+	w := quotedprintable.NewWriter(bu)
+
+	if _, err := w.Write([]byte(text)); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	bu.WriteString("\r\n")
+
+	return nil
+}
+
+func messageID(id string, from string) string {
+
+	domain := "localhost"
+	if i := strings.LastIndex(from, "@"); i >= 0 {
+		domain = from[i+1:]
+	}
+
+	if id == "" {
+		id = fmt.Sprintf("%d.%d", time.Now().UnixNano(), os.Getpid())
+	}
+
+	return "<" + id + "@" + domain + ">"
+}
+
+// envelopeAddress bare address for MAIL FROM and RCPT TO
+func envelopeAddress(value string) (string, error) {
+
+	addr, err := mail.ParseAddress(value)
+	if err != nil {
+		return "", &MessageError{fmt.Errorf("error address %q: %v", value, err)}
+	}
+
+	return addr.Address, nil
+}
@@ -0,0 +1,123 @@
+package utilsmtp
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// block elements, text is separated by line break
+var textBlockTags = map[string]bool{
+	"p": true, "div": true, "br": true, "tr": true, "li": true, "h1": true, "h2": true, "h3": true,
+	"h4": true, "h5": true, "h6": true, "table": true, "ul": true, "ol": true, "hr": true, "blockquote": true,
+}
+
+// skipped elements with content
+var textSkipTags = map[string]bool{"head": true, "title": true, "style": true, "script": true}
+
+// HTMLToText plain text alternative of html, links are kept as `text (url)`
+func HTMLToText(value string) string {
+
+	z := html.NewTokenizer(strings.NewReader(value))
+
+	bu := strings.Builder{}
+	skip := 0
+	hrefs := []string{}
+
+	newLine := func() {
+		text := bu.String()
+		if text != "" && !strings.HasSuffix(text, "\n") {
+			bu.WriteString("\n")
+		}
+	}
+
+	for {
+		tt := z.Next()
+
+		switch tt {
+		case html.ErrorToken:
+			return cleanText(bu.String())
+
+		case html.TextToken:
+			if skip == 0 {
+				raw := z.Raw()
+				text := strings.Join(strings.Fields(html.UnescapeString(string(raw))), " ")
+				if text == "" {
+					if isSpace(raw) && !endsWithSpace(bu.String()) {
+						bu.WriteString(" ")
+					}
+					continue
+				}
+				if isSpace(raw[:1]) && !endsWithSpace(bu.String()) {
+					bu.WriteString(" ")
+				}
+				bu.WriteString(text)
+				if isSpace(raw[len(raw)-1:]) {
+					bu.WriteString(" ")
+				}
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			name, hasAttr := z.TagName()
+			tag := string(name)
+
+			if textSkipTags[tag] {
+				if tt == html.StartTagToken {
+					skip++
+				} else if tt == html.EndTagToken && skip > 0 {
+					skip--
+				}
+				continue
+			}
+
+			if tag == "a" {
+				if tt == html.StartTagToken {
+					href := ""
+					for hasAttr {
+						var k, v []byte
+						k, v, hasAttr = z.TagAttr()
+						if string(k) == "href" {
+							href = string(v)
+						}
+					}
+					hrefs = append(hrefs, href)
+				} else if tt == html.EndTagToken && len(hrefs) > 0 {
+					href := hrefs[len(hrefs)-1]
+					hrefs = hrefs[:len(hrefs)-1]
+					if href != "" && !strings.HasPrefix(href, "#") && !strings.HasSuffix(strings.TrimSpace(bu.String()), href) {
+						bu.WriteString(" (" + href + ")")
+					}
+				}
+			}
+
+			if textBlockTags[tag] {
+				newLine()
+			}
+		}
+	}
+}
+
+func isSpace(raw []byte) bool {
+	return len(raw) > 0 && strings.TrimSpace(string(raw)) == ""
+}
+
+func endsWithSpace(value string) bool {
+	return value == "" || strings.HasSuffix(value, " ") || strings.HasSuffix(value, "\n")
+}
+
+// cleanText trim lines, no more than one empty line in row
+func cleanText(value string) string {
+
+	lines := strings.Split(value, "\n")
+	res := make([]string, 0, len(lines))
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" && (len(res) == 0 || res[len(res)-1] == "") {
+			continue
+		}
+		res = append(res, line)
+	}
+
+	return strings.TrimSpace(strings.Join(res, "\n"))
+}
@@ -0,0 +1,359 @@
+// Package utilsmtp smtp client tool
+package utilsmtp
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TLS modes
+const (
+	TLSNone     = "none"
+	TLSStartTLS = "starttls"
+	TLSImplicit = "tls"
+)
+
+// auth mechanisms
+const (
+	AuthNone  = "none"
+	AuthPlain = "plain"
+	AuthLogin = "login"
+)
+
+// Config smtp connection config
+type Config struct {
+	Host        string
+	Port        int
+	TLS         string // starttls, tls, none
+	Auth        string // plain, login, none
+	User        string
+	Password    string
+	Timeout     time.Duration // dial timeout
+	IdleTimeout time.Duration // reconnect if connection idle longer, 0 no limit
+	TLSConfig   *tls.Config   // optional, default verify by Host
+}
+
+// Client smtp client, keeps connection open between messages
+type Client struct {
+	config   Config
+	mu       sync.Mutex
+	client   *smtp.Client
+	lastUsed time.Time
+}
+
+// NewClient new client, connection is opened on first send
+func NewClient(config Config) *Client {
+	return &Client{config: config}
+}
+
+// Send send message, reuse open connection or reconnect once if it is broken
+func (x *Client) Send(msg Message) error {
+
+	data, err := BuildMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	from, err := envelopeAddress(msg.From)
+	if err != nil {
+		return err
+	}
+
+	rcpt := make([]string, 0, len(msg.To)+len(msg.Cc)+len(msg.Bcc))
+	for _, v := range slices.Concat(msg.To, msg.Cc, msg.Bcc) {
+		addr, err := envelopeAddress(v)
+		if err != nil {
+			return err
+		}
+		rcpt = append(rcpt, addr)
+	}
+
+	if len(rcpt) == 0 {
+		return &MessageError{fmt.Errorf("error smtp recipient is empty")}
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	reused := x.client != nil
+
+	err = x.send(from, rcpt, data)
+
+	if err != nil && reused && !isProtocolError(err) {
+		// connection may be closed by server, retry on new one
+		err = x.send(from, rcpt, data)
+	}
+
+	return err
+}
+
+// Close close open connection
+func (x *Client) Close() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.client == nil {
+		return nil
+	}
+
+	err := x.client.Quit()
+	x.client = nil
+
+	return err
+}
+
+func (x *Client) send(from string, rcpt []string, data []byte) error {
+
+	c, err := x.connection()
+	if err != nil {
+		return err
+	}
+
+	err = func() error {
+		if err := c.Mail(from); err != nil {
+			return err
+		}
+		for _, v := range rcpt {
+			if err := c.Rcpt(v); err != nil {
+				return err
+			}
+		}
+
+		w, err := c.Data()
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			_ = w.Close()
+			return err
+		}
+		return w.Close()
+	}()
+
+	if err != nil {
+		if isProtocolError(err) {
+			_ = c.Reset() // keep connection, drop transaction
+		} else {
+			x.drop()
+		}
+		return err
+	}
+
+	x.lastUsed = time.Now()
+
+	return nil
+}
+
+// connection open connection or check existing one
+func (x *Client) connection() (*smtp.Client, error) {
+
+	if x.client != nil {
+
+		idle := x.config.IdleTimeout > 0 && time.Since(x.lastUsed) > x.config.IdleTimeout
+
+		if !idle && x.client.Noop() == nil {
+			return x.client, nil
+		}
+
+		x.drop()
+	}
+
+	c, err := x.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	x.client = c
+	x.lastUsed = time.Now()
+
+	return c, nil
+}
+
+func (x *Client) drop() {
+	if x.client != nil {
+		_ = x.client.Close()
+		x.client = nil
+	}
+}
+
+func (x *Client) dial() (*smtp.Client, error) {
+
+	cfg := x.config
+
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("error smtp host is empty")
+	}
+
+	port := cfg.Port
+	if port == 0 {
+		port = defaultPort(cfg.TLS)
+	}
+
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+
+	tlsConfig := cfg.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	tlsConfig = tlsConfig.Clone()
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = cfg.Host
+	}
+
+	var conn net.Conn
+	var err error
+
+	switch strings.ToLower(cfg.TLS) {
+	case TLSImplicit:
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	case TLSStartTLS, TLSNone, "":
+		conn, err = dialer.Dial("tcp", addr)
+	default:
+		return nil, fmt.Errorf("error smtp tls mode not supported: %v", cfg.TLS)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error smtp dial %v: %v", addr, err)
+	}
+
+	c, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	if err := x.handshake(c, tlsConfig); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (x *Client) handshake(c *smtp.Client, tlsConfig *tls.Config) error {
+
+	cfg := x.config
+
+	if err := c.Hello(localName()); err != nil {
+		return err
+	}
+
+	if strings.ToLower(cfg.TLS) == TLSStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("error smtp server does not support STARTTLS")
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	var auth smtp.Auth
+
+	switch strings.ToLower(cfg.Auth) {
+	case AuthNone, "":
+		return nil
+	case AuthPlain:
+		auth = smtp.PlainAuth("", cfg.User, cfg.Password, cfg.Host)
+	case AuthLogin:
+		auth = &loginAuth{user: cfg.User, password: cfg.Password, host: cfg.Host}
+	default:
+		return fmt.Errorf("error smtp auth not supported: %v", cfg.Auth)
+	}
+
+	return c.Auth(auth)
+}
+
+func defaultPort(tlsMode string) int {
+	switch strings.ToLower(tlsMode) {
+	case TLSImplicit:
+		return 465
+	case TLSStartTLS:
+		return 587
+	}
+	return 25
+}
+
+func localName() string {
+	if name, err := os.Hostname(); err == nil && name != "" {
+		return name
+	}
+	return "localhost"
+}
+
+// IsPermanent smtp 5xx reply or invalid message, message will not be accepted on retry
+func IsPermanent(err error) bool {
+
+	var msgErr *MessageError
+	if errors.As(err, &msgErr) {
+		return true
+	}
+
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code >= 500
+	}
+
+	return false
+}
+
+// isProtocolError smtp server reply, connection is alive
+func isProtocolError(err error) bool {
+	var protoErr *textproto.Error
+	return errors.As(err, &protoErr)
+}
+
+// loginAuth AUTH LOGIN mechanism, not in net/smtp
+type loginAuth struct {
+	user     string
+	password string
+	host     string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+
+	if !more {
+		return nil, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:", "username":
+		return []byte(a.user), nil
+	case "password:", "password":
+		return []byte(a.password), nil
+	}
+
+	return nil, fmt.Errorf("unexpected server challenge: %s", fromServer)
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
@@ -0,0 +1,467 @@
+package utilsmtp
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer in-process smtp server for testing
+type fakeServer struct {
+	listener  net.Listener
+	tlsConfig *tls.Config // STARTTLS if set
+	user      string
+	password  string
+
+	mu       sync.Mutex
+	conns    int
+	auths    []string
+	messages []fakeMessage
+}
+
+type fakeMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	x := &fakeServer{listener: listener, user: "user@example.com", password: "secret"}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			x.mu.Lock()
+			x.conns++
+			x.mu.Unlock()
+			go x.serve(conn)
+		}
+	}()
+
+	t.Cleanup(func() { _ = listener.Close() })
+
+	return x
+}
+
+func (x *fakeServer) port() int {
+	return x.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (x *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	w := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+	read := func() string {
+		line, _ := r.ReadString('\n')
+		return strings.TrimRight(line, "\r\n")
+	}
+
+	isTLS := false
+	msg := fakeMessage{}
+
+	w("220 fake ESMTP")
+
+	for {
+		line := read()
+		cmd := strings.ToUpper(line)
+
+		switch {
+		case line == "":
+			return
+		case strings.HasPrefix(cmd, "EHLO"):
+			w("250-fake")
+			if x.tlsConfig != nil && !isTLS {
+				w("250-STARTTLS")
+			}
+			w("250-AUTH PLAIN LOGIN")
+			w("250 8BITMIME")
+		case cmd == "STARTTLS":
+			w("220 ready")
+			tlsConn := tls.Server(conn, x.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			r = bufio.NewReader(conn)
+			isTLS = true
+		case strings.HasPrefix(cmd, "AUTH PLAIN"):
+			data, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(line[len("AUTH PLAIN"):]))
+			parts := strings.Split(string(data), "\x00")
+			x.auth("plain", len(parts) == 3 && parts[1] == x.user && parts[2] == x.password, w)
+		case cmd == "AUTH LOGIN":
+			w("334 " + base64.StdEncoding.EncodeToString([]byte("Username:")))
+			user, _ := base64.StdEncoding.DecodeString(read())
+			w("334 " + base64.StdEncoding.EncodeToString([]byte("Password:")))
+			password, _ := base64.StdEncoding.DecodeString(read())
+			x.auth("login", string(user) == x.user && string(password) == x.password, w)
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			msg = fakeMessage{From: pathAddress(line[len("MAIL FROM:"):])}
+			w("250 ok")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			to := pathAddress(line[len("RCPT TO:"):])
+			if strings.HasSuffix(to, "@reject.example.com") {
+				w("550 no such user")
+				continue
+			}
+			msg.To = append(msg.To, to)
+			w("250 ok")
+		case cmd == "DATA":
+			w("354 go ahead")
+			bu := strings.Builder{}
+			for {
+				l := read()
+				if l == "." {
+					break
+				}
+				bu.WriteString(l + "\n")
+			}
+			msg.Data = bu.String()
+			x.mu.Lock()
+			x.messages = append(x.messages, msg)
+			x.mu.Unlock()
+			w("250 queued")
+		case cmd == "RSET", cmd == "NOOP":
+			w("250 ok")
+		case cmd == "QUIT":
+			w("221 bye")
+			return
+		default:
+			w("502 not implemented")
+		}
+	}
+}
+
+// pathAddress address from `<mail> PARAMS`
+func pathAddress(value string) string {
+	value, _, _ = strings.Cut(strings.TrimSpace(value), " ")
+	return strings.Trim(value, "<>")
+}
+
+func (x *fakeServer) auth(mechanism string, ok bool, w func(string)) {
+	x.mu.Lock()
+	x.auths = append(x.auths, mechanism)
+	x.mu.Unlock()
+
+	if ok {
+		w("235 authenticated")
+	} else {
+		w("535 bad credentials")
+	}
+}
+
+func (x *fakeServer) stats() (int, []string, []fakeMessage) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.conns, append([]string{}, x.auths...), append([]fakeMessage{}, x.messages...)
+}
+
+// Test send with PLAIN auth and connection reuse
+func TestClient_SendReuseConnection(t *testing.T) {
+	server := newFakeServer(t)
+
+	client := NewClient(Config{
+		Host:     "127.0.0.1",
+		Port:     server.port(),
+		TLS:      TLSNone,
+		Auth:     AuthPlain,
+		User:     "user@example.com",
+		Password: "secret",
+	})
+	defer client.Close()
+
+	for i := 0; i < 3; i++ {
+		err := client.Send(Message{
+			From:    "App <noreply@example.com>",
+			To:      []string{"user@example.com"},
+			Subject: "Secret code",
+			HTML:    "<b>12345678</b>",
+		})
+		if err != nil {
+			t.Fatalf("Send error: %v", err)
+		}
+	}
+
+	conns, auths, messages := server.stats()
+
+	if conns != 1 {
+		t.Errorf("Expected 1 connection, got %d", conns)
+	}
+	if len(auths) != 1 || auths[0] != "plain" {
+		t.Errorf("Expected single plain auth, got %v", auths)
+	}
+	if len(messages) != 3 {
+		t.Fatalf("Expected 3 messages, got %d", len(messages))
+	}
+	if messages[0].From != "noreply@example.com" || messages[0].To[0] != "user@example.com" {
+		t.Errorf("Unexpected envelope: %+v", messages[0])
+	}
+	if !strings.Contains(messages[0].Data, "<b>12345678</b>") {
+		t.Errorf("Expected html in body, got %q", messages[0].Data)
+	}
+}
+
+// Test LOGIN auth and rejected recipient keeps connection
+func TestClient_LoginAuthAndReject(t *testing.T) {
+	server := newFakeServer(t)
+
+	client := NewClient(Config{
+		Host:     "127.0.0.1",
+		Port:     server.port(),
+		Auth:     AuthLogin,
+		User:     "user@example.com",
+		Password: "secret",
+	})
+	defer client.Close()
+
+	err := client.Send(Message{From: "noreply@example.com", To: []string{"user@reject.example.com"}, HTML: "x"})
+	if err == nil || !IsPermanent(err) {
+		t.Fatalf("Expected permanent error, got %v", err)
+	}
+
+	err = client.Send(Message{From: "noreply@example.com", To: []string{"user@example.com"}, HTML: "x"})
+	if err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+
+	conns, auths, messages := server.stats()
+	if conns != 1 || len(auths) != 1 || auths[0] != "login" || len(messages) != 1 {
+		t.Errorf("Unexpected server state: conns=%d auths=%v messages=%d", conns, auths, len(messages))
+	}
+}
+
+// Test wrong credentials
+func TestClient_AuthFailed(t *testing.T) {
+	server := newFakeServer(t)
+
+	client := NewClient(Config{
+		Host: "127.0.0.1", Port: server.port(), Auth: AuthPlain,
+		User: "user@example.com", Password: "wrong",
+	})
+	defer client.Close()
+
+	err := client.Send(Message{From: "noreply@example.com", To: []string{"user@example.com"}, HTML: "x"})
+	if err == nil {
+		t.Fatal("Expected auth error")
+	}
+}
+
+// Test STARTTLS upgrade before auth
+func TestClient_StartTLS(t *testing.T) {
+	server := newFakeServer(t)
+
+	cert, pool := newTestCert(t)
+	server.tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+
+	client := NewClient(Config{
+		Host:      "127.0.0.1",
+		Port:      server.port(),
+		TLS:       TLSStartTLS,
+		Auth:      AuthLogin,
+		User:      "user@example.com",
+		Password:  "secret",
+		TLSConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+		Timeout:   5 * time.Second,
+	})
+	defer client.Close()
+
+	err := client.Send(Message{From: "noreply@example.com", To: []string{"user@example.com"}, HTML: "x"})
+	if err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+
+	_, _, messages := server.stats()
+	if len(messages) != 1 {
+		t.Errorf("Expected 1 message, got %d", len(messages))
+	}
+}
+
+// Test MIME encoding of subject and body
+func TestBuildMessage(t *testing.T) {
+	data, err := BuildMessage(Message{
+		ID:      "abc",
+		From:    "Приложение <noreply@example.com>",
+		To:      []string{"user@example.com"},
+		Subject: "Código secreto",
+		HTML:    "<p>código: 12345678</p>",
+	})
+	if err != nil {
+		t.Fatalf("BuildMessage error: %v", err)
+	}
+
+	text := string(data)
+
+	if !strings.Contains(text, "Subject: "+mime.QEncoding.Encode("utf-8", "Código secreto")+"\r\n") {
+		t.Errorf("Subject not encoded: %q", text)
+	}
+	if !strings.Contains(text, "Message-ID: <abc@example.com>\r\n") {
+		t.Errorf("Unexpected Message-ID: %q", text)
+	}
+	if !strings.Contains(text, "c=C3=B3digo") {
+		t.Errorf("Body not quoted-printable: %q", text)
+	}
+
+	if _, err := BuildMessage(Message{From: "bad", To: []string{"user@example.com"}}); err == nil {
+		t.Error("Expected error for bad from address")
+	}
+
+	data, _ = BuildMessage(Message{From: "noreply@example.com", To: []string{"user@example.com"}, Subject: "a\r\nBcc: x@example.com"})
+	if strings.Contains(string(data), "\r\nBcc:") {
+		t.Error("Header injection not prevented")
+	}
+}
+
+func newTestCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(parsed)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+// Test multipart structure with text alternative, inline image and attachment
+func TestBuildMessage_Multipart(t *testing.T) {
+	data, err := BuildMessage(Message{
+		From:    "noreply@example.com",
+		To:      []string{"user@example.com"},
+		Cc:      []string{"cc@example.com"},
+		Bcc:     []string{"bcc@example.com"},
+		ReplyTo: "support@example.com",
+		Subject: "Invoice",
+		HTML:    `<p>Invoice <img src="cid:logo"></p>`,
+		Text:    "Invoice",
+		Headers: map[string]string{"X-Campaign": "spring"},
+		Attachments: []Attachment{
+			{Filename: "logo.png", ContentID: "logo", Data: []byte("png")},
+			{Filename: "счёт.pdf", Data: []byte("%PDF")},
+		},
+	})
+	if err != nil {
+		t.Fatalf("BuildMessage error: %v", err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Header.Get("Bcc") != "" || msg.Header.Get("Cc") != "<cc@example.com>" ||
+		msg.Header.Get("Reply-To") != "<support@example.com>" || msg.Header.Get("X-Campaign") != "spring" {
+		t.Errorf("Unexpected headers: %v", msg.Header)
+	}
+
+	// mixed(alternative(text, related(html, logo)), pdf)
+	types := []string{}
+	var walk func(r io.Reader, contentType string)
+	walk = func(r io.Reader, contentType string) {
+		mediaType, params, _ := mime.ParseMediaType(contentType)
+		types = append(types, mediaType)
+		if !strings.HasPrefix(mediaType, "multipart/") {
+			return
+		}
+		mr := multipart.NewReader(r, params["boundary"])
+		for {
+			p, err := mr.NextRawPart()
+			if err != nil {
+				return
+			}
+			walk(p, p.Header.Get("Content-Type"))
+		}
+	}
+	walk(msg.Body, msg.Header.Get("Content-Type"))
+
+	expected := "multipart/mixed multipart/alternative text/plain multipart/related text/html image/png application/pdf"
+	if strings.Join(types, " ") != expected {
+		t.Errorf("Unexpected structure: %v", types)
+	}
+
+	if !strings.Contains(string(data), "Content-Id: <logo>") {
+		t.Errorf("Content-ID missing")
+	}
+
+	if _, err := BuildMessage(Message{From: "noreply@example.com", To: []string{"user@example.com"},
+		Headers: map[string]string{"Content-Type": "text/plain"}}); err == nil || !IsPermanent(err) {
+		t.Errorf("Expected reserved header error, got %v", err)
+	}
+}
+
+// Test Cc and Bcc are envelope recipients
+func TestClient_SendBcc(t *testing.T) {
+	server := newFakeServer(t)
+
+	client := NewClient(Config{Host: "127.0.0.1", Port: server.port()})
+	defer client.Close()
+
+	err := client.Send(Message{From: "noreply@example.com", To: []string{"user@example.com"},
+		Cc: []string{"cc@example.com"}, Bcc: []string{"bcc@example.com"}, HTML: "x"})
+	if err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+
+	_, _, messages := server.stats()
+	if len(messages) != 1 || strings.Join(messages[0].To, ",") != "user@example.com,cc@example.com,bcc@example.com" {
+		t.Errorf("Unexpected envelope: %+v", messages)
+	}
+	if strings.Contains(messages[0].Data, "bcc@example.com") {
+		t.Error("Bcc in message data")
+	}
+}
+
+// Test plain text from html
+func TestHTMLToText(t *testing.T) {
+	text := HTMLToText(`<html><head><title>T</title><style>p{}</style></head><body>
+		<h1>Secret&nbsp;code</h1><p>Your <b>code</b> is 1234.</p>
+		<p><a href="https://example.com/help">Help</a><br>Bye</p></body></html>`)
+
+	expected := "Secret code\nYour code is 1234.\nHelp (https://example.com/help)\nBye"
+	if text != expected {
+		t.Errorf("Expected %q, got %q", expected, text)
+	}
+}
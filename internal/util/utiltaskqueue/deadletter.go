@@ -0,0 +1,92 @@
+package utiltaskqueue
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DeadLetter task failed after all attempts
+type DeadLetter[T any] struct {
+	ID       string    `json:"id"`
+	Data     *T        `json:"data"`
+	Error    string    `json:"error"`
+	Attempts int       `json:"attempts"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// DeadLetterStore store for failed tasks
+type DeadLetterStore[T any] interface {
+	Add(item DeadLetter[T])
+	List() []DeadLetter[T]
+	Take(id string) (DeadLetter[T], bool)
+	Len() int
+}
+
+// MemoryDeadLetterStore bounded in-memory store, oldest dropped on overflow
+type MemoryDeadLetterStore[T any] struct {
+	list    list.List
+	mu      sync.Mutex
+	maxSize int
+}
+
+// NewMemoryDeadLetterStore new store, maxSize <= 0 no limit
+func NewMemoryDeadLetterStore[T any](maxSize int) *MemoryDeadLetterStore[T] {
+	return &MemoryDeadLetterStore[T]{maxSize: maxSize}
+}
+
+// Add add item, drop oldest if full
+func (x *MemoryDeadLetterStore[T]) Add(item DeadLetter[T]) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.removeUnsafe(item.ID) // keep last one
+
+	x.list.PushBack(item)
+
+	for x.maxSize > 0 && x.list.Len() > x.maxSize {
+		x.list.Remove(x.list.Front())
+	}
+}
+
+// List items from oldest
+func (x *MemoryDeadLetterStore[T]) List() []DeadLetter[T] {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	res := make([]DeadLetter[T], 0, x.list.Len())
+	for el := x.list.Front(); el != nil; el = el.Next() {
+		res = append(res, el.Value.(DeadLetter[T]))
+	}
+
+	return res
+}
+
+// Take remove and return item
+func (x *MemoryDeadLetterStore[T]) Take(id string) (DeadLetter[T], bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	return x.removeUnsafe(id)
+}
+
+// Len count of items
+func (x *MemoryDeadLetterStore[T]) Len() int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	return x.list.Len()
+}
+
+func (x *MemoryDeadLetterStore[T]) removeUnsafe(id string) (DeadLetter[T], bool) {
+
+	for el := x.list.Front(); el != nil; el = el.Next() {
+		item := el.Value.(DeadLetter[T])
+		if item.ID == id {
+			x.list.Remove(el)
+			return item, true
+		}
+	}
+
+	return DeadLetter[T]{}, false
+}
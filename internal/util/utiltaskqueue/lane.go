@@ -0,0 +1,124 @@
+package utiltaskqueue
+
+import "container/list"
+
+// Priority lane of task, normal by default
+type Priority int
+
+// task priorities
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+// lane scheduling
+const (
+	SchedulingStrict   = "strict"   // higher lane first, default
+	SchedulingWeighted = "weighted" // smooth weighted round robin by lane weight, no lane starves
+)
+
+// priorities lanes from highest
+var priorities = [...]Priority{PriorityHigh, PriorityNormal, PriorityLow}
+
+// TaskPriority task with own priority
+type TaskPriority interface {
+	TaskPriority() Priority
+}
+
+// LaneConfig lane weight for weighted scheduling and size limit
+type LaneConfig struct {
+	Weight  int // default 1
+	MaxSize int // no limit if <= 0, MaxQueueSize is checked too
+}
+
+// LaneStats lane stats
+type LaneStats struct {
+	Priority  Priority
+	QueueSize int
+	MaxSize   int
+	Weight    int
+	Enqueued  int64
+	Processed int64
+}
+
+// lane tasks of one priority
+type lane[T any] struct {
+	list      list.List
+	current   int // weighted round robin state
+	enqueued  int64
+	processed int64
+}
+
+// laneIndex index of lanes, unknown priority is clamped
+func laneIndex(p Priority) int {
+	switch {
+	case p >= PriorityHigh:
+		return 0
+	case p <= PriorityLow:
+		return 2
+	}
+	return 1
+}
+
+// priorityOf task priority, normal if data has no priority
+func priorityOf(data any) Priority {
+	if v, ok := data.(TaskPriority); ok {
+		return v.TaskPriority()
+	}
+	return PriorityNormal
+}
+
+// laneConfig config of lane, weight defaulted
+func (x *TaskQueue[T]) laneConfig(i int) LaneConfig {
+	res := x.Lanes[priorities[i]]
+	if res.Weight <= 0 {
+		res.Weight = 1
+	}
+	return res
+}
+
+// lenUnsafe tasks in all lanes
+func (x *TaskQueue[T]) lenUnsafe() int {
+	res := 0
+	for i := range x.lanes {
+		res += x.lanes[i].list.Len()
+	}
+	return res
+}
+
+// nextLaneUnsafe lane to pop from, -1 if all are empty
+func (x *TaskQueue[T]) nextLaneUnsafe() int {
+
+	if x.Scheduling != SchedulingWeighted {
+		for i := range x.lanes {
+			if x.lanes[i].list.Len() > 0 {
+				return i
+			}
+		}
+		return -1
+	}
+
+	res := -1
+	total := 0
+
+	for i := range x.lanes {
+		if x.lanes[i].list.Len() == 0 {
+			continue
+		}
+
+		weight := x.laneConfig(i).Weight
+		total += weight
+		x.lanes[i].current += weight
+
+		if res < 0 || x.lanes[i].current > x.lanes[res].current {
+			res = i
+		}
+	}
+
+	if res >= 0 {
+		x.lanes[res].current -= total
+	}
+
+	return res
+}
@@ -0,0 +1,78 @@
+package utiltaskqueue
+
+import (
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy retry failed tasks with exponential backoff
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts, no retry if <= 1
+	BaseDelay   time.Duration // delay before second attempt, doubled each next one
+	MaxDelay    time.Duration // 0 no limit
+	Jitter      float64       // 0..1, part of delay randomized down
+	Retryable   func(error) bool
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent mark error as not retryable
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent check if error marked as not retryable
+func IsPermanent(err error) bool {
+	var target *permanentError
+	return errors.As(err, &target)
+}
+
+func (x RetryPolicy) shouldRetry(attempts int, err error) bool {
+
+	if attempts >= x.MaxAttempts {
+		return false
+	}
+
+	if IsPermanent(err) {
+		return false
+	}
+
+	if x.Retryable != nil {
+		return x.Retryable(err)
+	}
+
+	return true
+}
+
+// delay backoff before next attempt, attempts is count of done attempts
+func (x RetryPolicy) delay(attempts int) time.Duration {
+
+	res := x.BaseDelay
+
+	for i := 1; i < attempts; i++ {
+		res *= 2
+		if x.MaxDelay > 0 && res >= x.MaxDelay {
+			break
+		}
+	}
+
+	if x.MaxDelay > 0 && res > x.MaxDelay {
+		res = x.MaxDelay
+	}
+
+	if x.Jitter > 0 && res > 0 {
+		jitter := min(x.Jitter, 1)
+		res -= time.Duration(rand.Float64() * jitter * float64(res)) //nolint:gosec
+	}
+
+	return res
+}
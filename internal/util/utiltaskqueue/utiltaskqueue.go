@@ -2,41 +2,94 @@
 package utiltaskqueue
 
 import (
-	"container/list"
+	"errors"
 	"fmt"
 	xlog "go-infra/internal/util/utillog"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// ErrDeadLetterNotFound dead letter not exists or can not be requeued
+var ErrDeadLetterNotFound = errors.New("dead letter not exists")
+
 // TaskQueueStats queue stats
 type TaskQueueStats struct {
-	QueueSize   int
-	WorkerCount int
-	MaxWorker   int
+	QueueSize       int
+	WorkerCount     int
+	MaxWorker       int
+	RetryCount      int // tasks waiting for retry delay
+	DeadLetterCount int
+	Lanes           []LaneStats // from highest priority
+}
+
+// task queue item with attempts counter
+type task[T any] struct {
+	data     *T
+	attempts int
+	lane     int
 }
 
 // TaskQueue task queue
 type TaskQueue[T any] struct {
 	handler       func(*T) error
-	list          list.List
+	lanes         [len(priorities)]lane[T]
 	mu            sync.Mutex
 	workerCounter int // not atomic allowed
 	maxWorker     int
 	isActive      bool // not atomic allowed, durty read-write allowed
 	name          string
 	MaxQueueSize  int // durty read-write allowed
+
+	Scheduling string                  // strict (default) or weighted between priority lanes
+	Lanes      map[Priority]LaneConfig // lane weight and size limit, set before first enqueue
+
+	Retry        RetryPolicy        // no retry if MaxAttempts <= 1
+	DeadLetters  DeadLetterStore[T] // nil, failed tasks are dropped
+	OnDeadLetter func(data *T, err error)
+
+	retryCounter atomic.Int32
+	deadSeq      atomic.Int64
+}
+
+// TaskIdentity task with own id, used as dead letter id
+type TaskIdentity interface {
+	TaskID() string
 }
 
 // Stats get stats
 func (x *TaskQueue[T]) Stats() TaskQueueStats {
 	// trigger for processing
 
-	return TaskQueueStats{
-		QueueSize:   x.list.Len(),
+	x.mu.Lock()
+
+	res := TaskQueueStats{
+		QueueSize:   x.lenUnsafe(),
 		WorkerCount: x.workerCounter,
 		MaxWorker:   x.maxWorker,
+		RetryCount:  int(x.retryCounter.Load()),
 	}
 
+	for i := range x.lanes {
+		cfg := x.laneConfig(i)
+		res.Lanes = append(res.Lanes, LaneStats{
+			Priority:  priorities[i],
+			QueueSize: x.lanes[i].list.Len(),
+			MaxSize:   cfg.MaxSize,
+			Weight:    cfg.Weight,
+			Enqueued:  x.lanes[i].enqueued,
+			Processed: x.lanes[i].processed,
+		})
+	}
+
+	x.mu.Unlock()
+
+	if x.DeadLetters != nil {
+		res.DeadLetterCount = x.DeadLetters.Len()
+	}
+
+	return res
 }
 
 // SetActive start-stop queue
@@ -51,12 +104,82 @@ func (x *TaskQueue[T]) SetActive(value bool) {
 func (x *TaskQueue[T]) Enqueue(data *T) error {
 	// trigger for processing
 
-	x.pushData(data)
+	if err := x.pushTask(&task[T]{data: data, lane: laneIndex(priorityOf(data))}); err != nil {
+		return err
+	}
+
 	x.tryRunWorker()
 
 	return nil
 }
 
+// EnqueueBatch add all to queue or none, queue and lane size limits are checked for whole batch
+func (x *TaskQueue[T]) EnqueueBatch(data []*T) error {
+
+	if !x.isActive {
+		return fmt.Errorf("task queue %v is not active", x.name)
+	}
+
+	tasks := make([]*task[T], 0, len(data))
+	sizes := [len(priorities)]int{}
+
+	for _, itm := range data {
+		if itm != nil {
+			item := &task[T]{data: itm, lane: laneIndex(priorityOf(itm))}
+			tasks = append(tasks, item)
+			sizes[item.lane]++
+		}
+	}
+
+	x.mu.Lock()
+
+	overloaded := x.MaxQueueSize > 0 && x.lenUnsafe()+len(tasks) > x.MaxQueueSize
+	for i := range x.lanes {
+		if maxSize := x.laneConfig(i).MaxSize; maxSize > 0 && x.lanes[i].list.Len()+sizes[i] > maxSize {
+			overloaded = true
+		}
+	}
+
+	if overloaded {
+		x.mu.Unlock()
+		xlog.Info("task queue %v  is overloaded", x.name)
+		return fmt.Errorf("task queue %v is overloaded", x.name)
+	}
+
+	for _, item := range tasks {
+		x.lanes[item.lane].list.PushFront(item)
+		x.lanes[item.lane].enqueued++
+	}
+
+	x.mu.Unlock()
+
+	for range min(len(data), x.maxWorker) {
+		x.tryRunWorker()
+	}
+
+	return nil
+}
+
+// Requeue move dead letter back to queue, attempts are reset, dead letter is kept if queue rejects it
+func (x *TaskQueue[T]) Requeue(id string) error {
+
+	if x.DeadLetters == nil {
+		return fmt.Errorf("task queue %v has no dead letter store", x.name)
+	}
+
+	item, ok := x.DeadLetters.Take(id)
+	if !ok {
+		return fmt.Errorf("%w: %v", ErrDeadLetterNotFound, id)
+	}
+
+	if err := x.Enqueue(item.Data); err != nil {
+		x.DeadLetters.Add(item) // back to store, not lost
+		return err
+	}
+
+	return nil
+}
+
 func (x *TaskQueue[T]) tryRunWorker() {
 
 	if !x.isActive {
@@ -99,11 +222,13 @@ func (x *TaskQueue[T]) runWorker() {
 
 			for x.isActive { // loop if data exists
 
-				data := x.popData()
-				if data == nil {
+				item := x.popTask()
+				if item == nil {
 					break
 				}
 
+				item.attempts++
+
 				// Handle potential panic inside task handler
 				err := func() (err error) {
 					defer func() {
@@ -114,11 +239,12 @@ func (x *TaskQueue[T]) runWorker() {
 							// err = fmt.Errorf("error panic: %v\n%s", r, debug.Stack())
 						}
 					}()
-					return x.handler(data)
+					return x.handler(item.data)
 				}()
 
 				if err != nil {
-					xlog.Error("task queue %s: %v", x.name, err)
+					xlog.Error("task queue %s: attempt %v: %v", x.name, item.attempts, err)
+					x.failTask(item, err)
 				}
 
 			}
@@ -136,41 +262,102 @@ func (x *TaskQueue[T]) hasFreeWorkerUnsafe() bool {
 
 // hasDataUnsafe is usafe and durty resula allowed
 func (x *TaskQueue[T]) hasDataUnsafe() bool {
-	return x.list.Len() > 0 // protect from loop
+	return x.lenUnsafe() > 0 // protect from loop
+}
+
+// failTask schedule retry or move task to dead letters
+func (x *TaskQueue[T]) failTask(item *task[T], err error) {
+
+	if x.Retry.shouldRetry(item.attempts, err) {
+
+		x.retryCounter.Add(1)
+
+		time.AfterFunc(x.Retry.delay(item.attempts), func() {
+			x.retryCounter.Add(-1)
+
+			if errPush := x.pushTask(item); errPush != nil {
+				x.deadLetter(item, errPush)
+				return
+			}
+			x.tryRunWorker()
+		})
+
+		return
+	}
+
+	x.deadLetter(item, err)
+}
+
+func (x *TaskQueue[T]) deadLetter(item *task[T], err error) {
+
+	if x.DeadLetters != nil {
+
+		id := ""
+		if v, ok := any(item.data).(TaskIdentity); ok {
+			id = v.TaskID()
+		}
+		if id == "" {
+			id = strconv.FormatInt(x.deadSeq.Add(1), 10)
+		}
+
+		x.DeadLetters.Add(DeadLetter[T]{
+			ID:       id,
+			Data:     item.data,
+			Error:    err.Error(),
+			Attempts: item.attempts,
+			FailedAt: time.Now(),
+		})
+	}
+
+	if x.OnDeadLetter != nil {
+		x.OnDeadLetter(item.data, err)
+	}
 }
 
-func (x *TaskQueue[T]) popData() *T {
+// popTask oldest task of lane chosen by scheduling
+func (x *TaskQueue[T]) popTask() *task[T] {
 	x.mu.Lock()
 	defer x.mu.Unlock()
 
-	if el := x.list.Back(); el != nil && el.Value != nil {
-		x.list.Remove(el)
-		data, _ := el.Value.(*T)
+	i := x.nextLaneUnsafe()
+	if i < 0 {
+		return nil
+	}
+
+	if el := x.lanes[i].list.Back(); el != nil && el.Value != nil {
+		x.lanes[i].list.Remove(el)
+		x.lanes[i].processed++
+		item, _ := el.Value.(*task[T])
 
-		return data
+		return item
 	}
 	return nil
 }
-func (x *TaskQueue[T]) pushData(data *T) {
+func (x *TaskQueue[T]) pushTask(item *task[T]) error {
 
 	if !x.isActive {
-		return
+		return fmt.Errorf("task queue %v is not active", x.name)
 	}
 
-	if data == nil {
-		return
+	if item.data == nil {
+		return nil
 	}
 
 	x.mu.Lock()
 	defer x.mu.Unlock()
 
-	if x.MaxQueueSize > 0 && x.list.Len() > x.MaxQueueSize {
+	maxSize := x.laneConfig(item.lane).MaxSize
+
+	if (x.MaxQueueSize > 0 && x.lenUnsafe() >= x.MaxQueueSize) ||
+		(maxSize > 0 && x.lanes[item.lane].list.Len() >= maxSize) {
 		xlog.Info("task queue %v  is overloaded", x.name)
-		return
+		return fmt.Errorf("task queue %v is overloaded", x.name)
 	}
 
-	x.list.PushFront(data)
+	x.lanes[item.lane].list.PushFront(item)
+	x.lanes[item.lane].enqueued++
 
+	return nil
 }
 
 func NewTaskQueue[T any](name string, handler func(*T) error, maxWorker int) *TaskQueue[T] {
@@ -63,3 +63,346 @@ func TestTaskQueue_ErrorHandling(t *testing.T) {
 	// No panic should have occurred, just error handling in logs
 	// You could also capture logs if needed, but for simplicity, it's not done here.
 }
+
+// Test retry until handler succeeds
+func TestTaskQueue_Retry(t *testing.T) {
+	var calls atomic.Int32
+
+	handler := func(task *TestTask) error {
+		if calls.Add(1) < 3 {
+			return errors.New("transient error")
+		}
+		return nil
+	}
+
+	queue := NewTaskQueue("retryQueue", handler, 1)
+	queue.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond}
+	queue.DeadLetters = NewMemoryDeadLetterStore[TestTask](10)
+
+	_ = queue.Enqueue(&TestTask{value: 1})
+
+	time.Sleep(300 * time.Millisecond)
+
+	if calls.Load() != 3 {
+		t.Errorf("Expected 3 calls, got %d", calls.Load())
+	}
+
+	if queue.Stats().DeadLetterCount != 0 {
+		t.Errorf("Expected no dead letters, got %d", queue.Stats().DeadLetterCount)
+	}
+}
+
+// Test dead letter after all attempts and requeue
+func TestTaskQueue_DeadLetter(t *testing.T) {
+	var fail atomic.Bool
+	var processed atomic.Int32
+	var deadCalls atomic.Int32
+
+	fail.Store(true)
+
+	handler := func(task *TestTask) error {
+		if fail.Load() {
+			return errors.New("gateway error")
+		}
+		processed.Add(task.value)
+		return nil
+	}
+
+	queue := NewTaskQueue("deadQueue", handler, 1)
+	queue.Retry = RetryPolicy{MaxAttempts: 2, BaseDelay: 10 * time.Millisecond}
+	queue.DeadLetters = NewMemoryDeadLetterStore[TestTask](10)
+	queue.OnDeadLetter = func(_ *TestTask, _ error) { deadCalls.Add(1) }
+
+	_ = queue.Enqueue(&TestTask{value: 5})
+
+	time.Sleep(300 * time.Millisecond)
+
+	list := queue.DeadLetters.List()
+	if len(list) != 1 {
+		t.Fatalf("Expected 1 dead letter, got %d", len(list))
+	}
+	if list[0].Attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", list[0].Attempts)
+	}
+	if deadCalls.Load() != 1 {
+		t.Errorf("Expected 1 dead letter callback, got %d", deadCalls.Load())
+	}
+
+	fail.Store(false)
+
+	// rejected by inactive queue, kept in store
+	queue.SetActive(false)
+	if err := queue.Requeue(list[0].ID); err == nil || errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("Expected rejected by inactive queue, got %v", err)
+	}
+	if queue.DeadLetters.Len() != 1 {
+		t.Fatalf("Expected dead letter kept, got %d", queue.DeadLetters.Len())
+	}
+	queue.SetActive(true)
+
+	if err := queue.Requeue(list[0].ID); err != nil {
+		t.Fatalf("Requeue error: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if processed.Load() != 5 {
+		t.Errorf("Expected processed to be 5, got %d", processed.Load())
+	}
+	if queue.DeadLetters.Len() != 0 {
+		t.Errorf("Expected empty dead letters, got %d", queue.DeadLetters.Len())
+	}
+
+	if err := queue.Requeue(list[0].ID); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("Expected not found, got %v", err)
+	}
+}
+
+// Test permanent error is not retried
+func TestTaskQueue_PermanentError(t *testing.T) {
+	var calls atomic.Int32
+
+	handler := func(task *TestTask) error {
+		calls.Add(1)
+		return Permanent(errors.New("bad request"))
+	}
+
+	queue := NewTaskQueue("permanentQueue", handler, 1)
+	queue.Retry = RetryPolicy{MaxAttempts: 5, BaseDelay: 10 * time.Millisecond}
+	queue.DeadLetters = NewMemoryDeadLetterStore[TestTask](10)
+
+	_ = queue.Enqueue(&TestTask{value: 1})
+
+	time.Sleep(200 * time.Millisecond)
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected 1 call, got %d", calls.Load())
+	}
+	if queue.DeadLetters.Len() != 1 {
+		t.Errorf("Expected 1 dead letter, got %d", queue.DeadLetters.Len())
+	}
+}
+
+// Test backoff delay growth, limit and jitter
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	expected := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for i, v := range expected {
+		if d := policy.delay(i + 1); d != v*time.Millisecond {
+			t.Errorf("attempt %d: expected %v, got %v", i+1, v*time.Millisecond, d)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := policy.delay(2)
+		if d < 100*time.Millisecond || d > 200*time.Millisecond {
+			t.Fatalf("jitter delay out of range: %v", d)
+		}
+	}
+}
+
+// Test memory dead letter store drop oldest on overflow
+func TestMemoryDeadLetterStore_Overflow(t *testing.T) {
+	store := NewMemoryDeadLetterStore[TestTask](2)
+
+	store.Add(DeadLetter[TestTask]{ID: "1"})
+	store.Add(DeadLetter[TestTask]{ID: "2"})
+	store.Add(DeadLetter[TestTask]{ID: "3"})
+
+	list := store.List()
+	if len(list) != 2 || list[0].ID != "2" || list[1].ID != "3" {
+		t.Errorf("Unexpected store content: %v", list)
+	}
+
+	if _, ok := store.Take("1"); ok {
+		t.Error("Expected dropped item to be missing")
+	}
+}
+
+// Test batch is enqueued whole or rejected by queue size limit
+func TestTaskQueue_EnqueueBatch(t *testing.T) {
+	var processed atomic.Int32
+
+	handler := func(task *TestTask) error {
+		processed.Add(task.value)
+		return nil
+	}
+
+	queue := NewTaskQueue("batchQueue", handler, 2)
+	queue.MaxQueueSize = 3
+	queue.SetActive(false)
+
+	if err := queue.EnqueueBatch([]*TestTask{{value: 1}, {value: 2}, {value: 3}, {value: 4}}); err == nil {
+		t.Error("Expected error on inactive queue")
+	}
+
+	queue.SetActive(true)
+
+	if err := queue.EnqueueBatch([]*TestTask{{value: 1}, {value: 2}, {value: 3}, {value: 4}}); err == nil {
+		t.Error("Expected overloaded error")
+	}
+
+	if err := queue.EnqueueBatch([]*TestTask{{value: 1}, {value: 2}, {value: 3}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+
+	if processed.Load() != 6 {
+		t.Errorf("Expected processed to be 6, got %d", processed.Load())
+	}
+}
+
+// Task with priority for testing purposes
+type PriorityTask struct {
+	value    int32
+	priority Priority
+}
+
+func (x *PriorityTask) TaskPriority() Priority {
+	return x.priority
+}
+
+// runPriorityQueue process tasks by one worker after blocking task, processed values returned in order
+func runPriorityQueue(t *testing.T, queue *TaskQueue[PriorityTask], gate chan struct{}, processed chan int32, tasks []*PriorityTask) []int32 {
+
+	if err := queue.Enqueue(&PriorityTask{value: 0}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if <-processed != 0 {
+		t.Fatal("Expected blocking task first")
+	}
+
+	for _, itm := range tasks {
+		if err := queue.Enqueue(itm); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	close(gate)
+
+	res := []int32{}
+	for range tasks {
+		select {
+		case v := <-processed:
+			res = append(res, v)
+		case <-time.After(time.Second):
+			t.Fatalf("Expected %d tasks processed, got %d", len(tasks), len(res))
+		}
+	}
+
+	return res
+}
+
+// newPriorityQueue single worker queue blocked by first task until gate is closed
+func newPriorityQueue(name string) (*TaskQueue[PriorityTask], chan struct{}, chan int32) {
+
+	gate := make(chan struct{})
+	processed := make(chan int32, 100)
+
+	handler := func(task *PriorityTask) error {
+		processed <- task.value
+		if task.value == 0 {
+			<-gate
+		}
+		return nil
+	}
+
+	queue := NewTaskQueue(name, handler, 1)
+	queue.SetActive(true)
+
+	return queue, gate, processed
+}
+
+// Test strict scheduling takes higher lane first, fifo in lane
+func TestTaskQueue_PriorityStrict(t *testing.T) {
+
+	queue, gate, processed := newPriorityQueue("strictQueue")
+
+	res := runPriorityQueue(t, queue, gate, processed, []*PriorityTask{
+		{value: 1, priority: PriorityLow},
+		{value: 2, priority: PriorityNormal},
+		{value: 3, priority: PriorityHigh},
+		{value: 4, priority: PriorityLow},
+		{value: 5, priority: PriorityHigh},
+	})
+
+	expected := []int32{3, 5, 2, 1, 4}
+	for i, v := range expected {
+		if res[i] != v {
+			t.Fatalf("Expected order %v, got %v", expected, res)
+		}
+	}
+}
+
+// Test weighted scheduling shares worker by lane weight, low lane is not starved
+func TestTaskQueue_PriorityWeighted(t *testing.T) {
+
+	queue, gate, processed := newPriorityQueue("weightedQueue")
+	queue.Scheduling = SchedulingWeighted
+	queue.Lanes = map[Priority]LaneConfig{PriorityHigh: {Weight: 2}}
+
+	tasks := []*PriorityTask{}
+	for i := range 4 {
+		tasks = append(tasks,
+			&PriorityTask{value: 10 + int32(i), priority: PriorityHigh},
+			&PriorityTask{value: 20 + int32(i), priority: PriorityNormal},
+			&PriorityTask{value: 30 + int32(i), priority: PriorityLow},
+		)
+	}
+
+	res := runPriorityQueue(t, queue, gate, processed, tasks)
+
+	lanes := map[int32]int{}
+	for _, v := range res[:4] {
+		lanes[v/10]++
+	}
+
+	if lanes[1] != 2 || lanes[2] != 1 || lanes[3] != 1 {
+		t.Errorf("Expected 2 high, 1 normal and 1 low of first 4 tasks, got %v", res)
+	}
+}
+
+// Test lane size limit and lane stats
+func TestTaskQueue_PriorityLaneLimit(t *testing.T) {
+
+	queue, gate, processed := newPriorityQueue("laneQueue")
+	queue.Lanes = map[Priority]LaneConfig{PriorityLow: {MaxSize: 1}}
+
+	_ = queue.Enqueue(&PriorityTask{value: 0})
+	<-processed
+
+	if err := queue.Enqueue(&PriorityTask{value: 1, priority: PriorityLow}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := queue.Enqueue(&PriorityTask{value: 2, priority: PriorityLow}); err == nil {
+		t.Error("Expected overloaded low lane")
+	}
+	if err := queue.EnqueueBatch([]*PriorityTask{{value: 3, priority: PriorityLow}, {value: 4}}); err == nil {
+		t.Error("Expected overloaded low lane by batch")
+	}
+	if err := queue.Enqueue(&PriorityTask{value: 5, priority: PriorityHigh}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	stats := queue.Stats()
+	if stats.QueueSize != 2 || len(stats.Lanes) != 3 {
+		t.Fatalf("Expected 2 queued in 3 lanes, got %+v", stats)
+	}
+	if stats.Lanes[0].Priority != PriorityHigh || stats.Lanes[0].QueueSize != 1 {
+		t.Errorf("Expected 1 queued in high lane, got %+v", stats.Lanes[0])
+	}
+	if stats.Lanes[2].Priority != PriorityLow || stats.Lanes[2].QueueSize != 1 || stats.Lanes[2].MaxSize != 1 {
+		t.Errorf("Expected 1 queued in low lane of 1, got %+v", stats.Lanes[2])
+	}
+
+	// queue limit is checked as by batch, full queue takes no more
+	queue.MaxQueueSize = 2
+	if err := queue.Enqueue(&PriorityTask{value: 6}); err == nil {
+		t.Error("Expected overloaded queue")
+	}
+
+	close(gate)
+}
@@ -1,6 +1,7 @@
 package e2e
 
 import (
+	"encoding/json"
 	xcmd "go-infra/internal/cmd"
 	"go-infra/internal/util/utilhttp"
 	"os"
@@ -28,12 +29,12 @@ func TestCmd(t *testing.T) {
 		title  string
 		url    string
 		form   map[string]string
-		search string
+		status string
 	}{
-		{title: "test email-passcode", search: "123456789", url: "http://127.0.0.1:30780/sys/api/messenger/email-passcode", form: map[string]string{"to": "test@example.com", "passcode": "123456789", "lang": "en"}},
-		{title: "test sms-passcode", search: "123456789", url: "http://127.0.0.1:30780/sys/api/messenger/sms-passcode", form: map[string]string{"to": "123456", "passcode": "123456789", "lang": "en"}},
-		{title: "test email-html", search: "123456789", url: "http://127.0.0.1:30780/sys/api/messenger/email-html", form: map[string]string{"to": "test@example.com", "html": "123456789"}},
-		{title: "test sms-text", search: "123456789", url: "http://127.0.0.1:30780/sys/api/messenger/sms-text", form: map[string]string{"to": "123456", "text": "123456789"}},
+		{title: "test email-passcode", status: "queued", url: "http://127.0.0.1:30780/sys/api/messenger/email-passcode", form: map[string]string{"to": "test@example.com", "passcode": "123456789", "lang": "en"}},
+		{title: "test sms-passcode", status: "queued", url: "http://127.0.0.1:30780/sys/api/messenger/sms-passcode", form: map[string]string{"to": "+12025550100", "passcode": "123456789", "lang": "en"}},
+		{title: "test email-html", status: "queued", url: "http://127.0.0.1:30780/sys/api/messenger/email-html", form: map[string]string{"to": "test@example.com", "html": "123456789"}},
+		{title: "test sms-text", status: "queued", url: "http://127.0.0.1:30780/sys/api/messenger/sms-text", form: map[string]string{"to": "+12025550100", "text": "123456789"}},
 	}
 
 	for _, itm := range urls {
@@ -49,8 +50,27 @@ func TestCmd(t *testing.T) {
 				t.Errorf("Error : %v", err)
 			}
 
-			if !strings.Contains(string(arr), itm.search) {
-				t.Errorf("Error on %v", itm.url)
+			if strings.Contains(string(arr), "123456789") {
+				t.Errorf("Error message content in response on %v", itm.url)
+			}
+
+			resp := struct {
+				ID     string `json:"id"`
+				Status string `json:"status"`
+			}{}
+			_ = json.Unmarshal(arr, &resp)
+
+			if resp.Status != itm.status {
+				t.Errorf("Error on %v: status %q, want %q", itm.url, resp.Status, itm.status)
+			}
+
+			if resp.ID == "" {
+				t.Fatalf("Error no message id on %v", itm.url)
+			}
+
+			_, err = utilhttp.GetBytes("http://127.0.0.1:30780/sys/api/messenger/messages/"+resp.ID, nil, nil)
+			if err != nil {
+				t.Errorf("Error on message status: %v", err)
 			}
 
 		})